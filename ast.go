@@ -3,52 +3,207 @@ package cypher
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // Query represents the Cypher query root element.
 type Query struct {
 	Root *SingleQuery
+	// Union holds the queries combined with Root, in order.
+	Union []UnionPart
+
+	params []ParameterRef
 }
 
 func (q Query) String() string {
-	return q.Root.String()
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteString(q.Root.String())
+	for _, u := range q.Union {
+		_, _ = buf.WriteRune(' ')
+		_, _ = buf.WriteString(u.String())
+	}
+
+	return buf.String()
+}
+
+// UnionPart is a query combined with the previous one by UNION. Duplicated
+// rows are only kept with UNION ALL.
+type UnionPart struct {
+	All   bool
+	Query *SingleQuery
+}
+
+func (up UnionPart) String() string {
+	if up.All {
+		return "UNION ALL " + up.Query.String()
+	}
+	return "UNION " + up.Query.String()
+}
+
+// Parameters returns the parameters referenced by a parsed query, in the
+// order they first appear.
+func (q Query) Parameters() []ParameterRef {
+	return q.params
+}
+
+// ParameterRef lists the positions where a parameter is referenced.
+type ParameterRef struct {
+	Name      string
+	Positions []Pos
 }
 
 // SingleQuery ...
 type SingleQuery struct {
+	// Parts are the stages of a multi-part query, each one ending in a WITH
+	// projection, that precede the final part.
+	Parts       []QueryPart
 	Reading     []ReadingClause
+	Updating    []UpdatingClause
 	Distinct    bool
-	ReturnItems []Expr
+	ReturnAll   bool
+	ReturnItems []ProjectionItem
 	Order       []OrderBy
 	Skip        *Expr
 	Limit       *Expr
 }
 
+// HasReturn returns false for queries that end in an updating clause.
+func (sq SingleQuery) HasReturn() bool {
+	return sq.ReturnAll || len(sq.ReturnItems) > 0
+}
+
 func (sq SingleQuery) String() string {
-	var buf bytes.Buffer
+	var clauses []string
 
+	for _, part := range sq.Parts {
+		clauses = append(clauses, part.String())
+	}
 	for _, r := range sq.Reading {
+		clauses = append(clauses, r.String())
+	}
+	for _, u := range sq.Updating {
+		clauses = append(clauses, u.String())
+	}
+
+	if sq.HasReturn() || len(clauses) == 0 {
+		var buf bytes.Buffer
+		_, _ = buf.WriteString("RETURN ")
+		writeProjection(&buf, sq.Distinct, sq.ReturnAll, sq.ReturnItems, sq.Order, sq.Skip, sq.Limit)
+		clauses = append(clauses, buf.String())
+	}
+
+	return strings.Join(clauses, " ")
+}
+
+// QueryPart is a stage of a multi-part query.
+type QueryPart struct {
+	Reading  []ReadingClause
+	Updating []UpdatingClause
+	With     WithClause
+}
+
+func (qp QueryPart) String() string {
+	var buf bytes.Buffer
+
+	for _, r := range qp.Reading {
 		_, _ = buf.WriteString(r.String())
+		_, _ = buf.WriteRune(' ')
+	}
+	for _, u := range qp.Updating {
+		_, _ = buf.WriteString(u.String())
+		_, _ = buf.WriteRune(' ')
+	}
+	_, _ = buf.WriteString(qp.With.String())
+
+	return buf.String()
+}
+
+// WithClause projects the results of a query part to the next one.
+type WithClause struct {
+	Distinct bool
+	All      bool
+	Items    []ProjectionItem
+	Order    []OrderBy
+	Skip     *Expr
+	Limit    *Expr
+	Where    *Expr
+}
+
+func (wc WithClause) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteString("WITH ")
+	writeProjection(&buf, wc.Distinct, wc.All, wc.Items, wc.Order, wc.Skip, wc.Limit)
+
+	if w := wc.Where; w != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString((*w).String())
 	}
 
-	_, _ = buf.WriteString(" RETURN ")
+	return buf.String()
+}
 
-	if sq.Distinct {
+// writeProjection writes the body shared by projecting clauses, everything
+// after the clause keyword.
+func writeProjection(buf *bytes.Buffer, distinct, all bool, items []ProjectionItem, order []OrderBy, skip, limit *Expr) {
+	if distinct {
 		_, _ = buf.WriteString("DISTINCT ")
 	}
 
-	for _, i := range sq.ReturnItems {
-		_, _ = buf.WriteString(i.String())
+	if all {
+		_, _ = buf.WriteRune('*')
+	}
+	for i, item := range items {
+		if i > 0 || all {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString(item.String())
 	}
 
-	if len(sq.Order) > 0 {
+	if len(order) > 0 {
 		_, _ = buf.WriteString(" ORDER BY ")
-		for _, o := range sq.Order {
+		for i, o := range order {
+			if i > 0 {
+				_, _ = buf.WriteString(", ")
+			}
 			_, _ = buf.WriteString(o.String())
 		}
 	}
 
-	return buf.String()
+	if skip != nil {
+		_, _ = buf.WriteString(" SKIP ")
+		_, _ = buf.WriteString((*skip).String())
+	}
+
+	if limit != nil {
+		_, _ = buf.WriteString(" LIMIT ")
+		_, _ = buf.WriteString((*limit).String())
+	}
+}
+
+// ProjectionItem is an expression projected by RETURN or WITH, optionally
+// renamed with `AS alias`.
+type ProjectionItem struct {
+	Expr  Expr
+	Alias *Variable
+}
+
+// Name returns the name of the column the item projects to.
+func (pi ProjectionItem) Name() string {
+	if pi.Alias != nil {
+		return string(*pi.Alias)
+	}
+	return pi.Expr.String()
+}
+
+func (pi ProjectionItem) String() string {
+	if pi.Alias != nil {
+		return pi.Expr.String() + " AS " + pi.Alias.String()
+	}
+	return pi.Expr.String()
 }
 
 // ReadingClause ...
@@ -56,228 +211,1677 @@ type ReadingClause struct {
 	OptionalMatch bool
 	Pattern       []MatchPattern
 	Where         *Expr
-	// Unwind
-	// Call
+	Unwind        *UnwindClause
+	Call          *CallClause
+	Subquery      *SubqueryCall
 }
 
 func (rc ReadingClause) String() string {
+	if rc.Unwind != nil {
+		return rc.Unwind.String()
+	} else if rc.Call != nil {
+		return rc.Call.String()
+	} else if rc.Subquery != nil {
+		return rc.Subquery.String()
+	}
+
 	var buf bytes.Buffer
 
 	if rc.OptionalMatch {
-		_, _ = buf.WriteString(" OPTIONAL ")
+		_, _ = buf.WriteString("OPTIONAL ")
 	}
 
-	for _, p := range rc.Pattern {
+	_, _ = buf.WriteString("MATCH ")
+
+	for i, p := range rc.Pattern {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
 		_, _ = buf.WriteString(p.String())
 	}
 
 	if w := rc.Where; w != nil {
+		_, _ = buf.WriteString(" WHERE ")
 		_, _ = buf.WriteString((*w).String())
 	}
 
 	return buf.String()
 }
 
-// MatchPattern ...
-type MatchPattern struct {
-	Variable *Variable
-	Elements []PatternElement
+// UnwindClause expands a list into a sequence of rows.
+type UnwindClause struct {
+	Expr     Expr
+	Variable Variable
 }
 
-func (mp MatchPattern) String() string {
+func (uc UnwindClause) String() string {
+	return "UNWIND " + uc.Expr.String() + " AS " + uc.Variable.String()
+}
+
+// CallClause invokes a procedure, e.g. `CALL db.labels() YIELD label`.
+type CallClause struct {
+	Namespace []string
+	Name      string
+	Args      []Expr
+	// ImplicitArgs is set when the procedure is called without parens, so
+	// its arguments are taken from the parameters with the same names.
+	ImplicitArgs bool
+	YieldAll     bool
+	Yield        []YieldItem
+	Where        *Expr
+}
+
+// ProcedureName returns the fully qualified name of the procedure.
+func (cc CallClause) ProcedureName() string {
+	return strings.Join(append(append([]string{}, cc.Namespace...), cc.Name), ".")
+}
+
+func (cc CallClause) String() string {
 	var buf bytes.Buffer
 
-	buf.WriteString("MATCH ")
+	_, _ = buf.WriteString("CALL ")
+	_, _ = buf.WriteString(namespacedString(cc.Namespace, cc.Name))
 
-	if mp.Variable != nil {
-		_, _ = buf.WriteString((*mp.Variable).String())
-		_, _ = buf.WriteString(" = ")
+	if !cc.ImplicitArgs {
+		_, _ = buf.WriteRune('(')
+		for i, arg := range cc.Args {
+			if i > 0 {
+				_, _ = buf.WriteString(", ")
+			}
+			_, _ = buf.WriteString(arg.String())
+		}
+		_, _ = buf.WriteRune(')')
 	}
 
-	for _, e := range mp.Elements {
-		_, _ = buf.WriteString(e.String())
+	if cc.YieldAll {
+		_, _ = buf.WriteString(" YIELD *")
+	} else if len(cc.Yield) > 0 {
+		_, _ = buf.WriteString(" YIELD ")
+		for i, item := range cc.Yield {
+			if i > 0 {
+				_, _ = buf.WriteString(", ")
+			}
+			_, _ = buf.WriteString(item.String())
+		}
+	}
+
+	if w := cc.Where; w != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString((*w).String())
 	}
 
 	return buf.String()
 }
 
-// PatternElement ...
-type PatternElement interface {
-	patternElem()
-	String() string
+// namespacedString renders a dotted name, keywords need no quoting after a dot.
+func namespacedString(namespace []string, name string) string {
+	var buf bytes.Buffer
+	for i, n := range append(append([]string{}, namespace...), name) {
+		if i == 0 {
+			_, _ = buf.WriteString(quoteIdent(n))
+			continue
+		}
+		_, _ = buf.WriteRune('.')
+		_, _ = buf.WriteString(quoteKey(n))
+	}
+	return buf.String()
+}
+
+// SubqueryCall runs a nested query for each incoming row, e.g.
+// `CALL { WITH row CREATE (n) } IN TRANSACTIONS OF 1000 ROWS`.
+type SubqueryCall struct {
+	// Scope is set when variables are imported with `CALL (a, b) { ... }`.
+	Scope          *SubqueryScope
+	Query          Query
+	InTransactions *TransactionOptions
 }
 
-func (np NodePattern) patternElem() {}
-func (ep EdgePattern) patternElem() {}
+// Imports returns the variables imported into the subquery, either by its
+// scope or by a leading WITH of plain variables.
+func (sc SubqueryCall) Imports() []Variable {
+	if sc.Scope != nil {
+		return sc.Scope.Variables
+	}
 
-// NodePattern ...
-type NodePattern struct {
-	Variable   *Variable
-	Labels     []string
-	Properties map[string]Expr
+	root := sc.Query.Root
+	if root == nil || len(root.Parts) == 0 || len(root.Parts[0].Reading) > 0 || len(root.Parts[0].Updating) > 0 {
+		return nil
+	}
+	var vars []Variable
+	for _, item := range root.Parts[0].With.Items {
+		if v, ok := item.Expr.(Variable); ok && item.Alias == nil {
+			vars = append(vars, v)
+		}
+	}
+	return vars
 }
 
-func (np NodePattern) String() string {
+func (sc SubqueryCall) String() string {
 	var buf bytes.Buffer
 
-	_, _ = buf.WriteRune('(')
+	_, _ = buf.WriteString("CALL ")
+	if sc.Scope != nil {
+		_, _ = buf.WriteString(sc.Scope.String())
+		_, _ = buf.WriteRune(' ')
+	}
+	_, _ = buf.WriteString("{ ")
+	_, _ = buf.WriteString(sc.Query.String())
+	_, _ = buf.WriteString(" }")
 
-	if np.Variable != nil {
-		_, _ = buf.WriteString((*np.Variable).String())
+	if sc.InTransactions != nil {
+		_, _ = buf.WriteRune(' ')
+		_, _ = buf.WriteString(sc.InTransactions.String())
 	}
 
-	for _, l := range np.Labels {
-		_, _ = buf.WriteString(" :")
-		_, _ = buf.WriteString(l)
+	return buf.String()
+}
+
+// SubqueryScope lists the variables imported by a subquery, or all of them with `*`.
+type SubqueryScope struct {
+	All       bool
+	Variables []Variable
+}
+
+func (ss SubqueryScope) String() string {
+	if ss.All {
+		return "(*)"
 	}
+	vars := make([]string, len(ss.Variables))
+	for i, v := range ss.Variables {
+		vars[i] = v.String()
+	}
+	return "(" + strings.Join(vars, ", ") + ")"
+}
 
-	if len(np.Properties) > 0 {
-		_, _ = buf.WriteString(" {")
+// TransactionOptions are the options of a subquery run with IN TRANSACTIONS.
+type TransactionOptions struct {
+	Concurrent bool
+	// Concurrency is the number of transactions run in parallel, if given.
+	Concurrency *Expr
+	// BatchSize is the number of rows of each transaction, if given.
+	BatchSize    *Expr
+	OnError      OnErrorMode
+	ReportStatus *Variable
+}
 
-		var next bool
-		for p, v := range np.Properties {
-			if next {
-				_, _ = buf.WriteRune(',')
-			}
-			_, _ = buf.WriteString(p)
-			_, _ = buf.WriteString(": ")
-			_, _ = buf.WriteString(v.String())
-			next = true
-		}
+func (to TransactionOptions) String() string {
+	var buf bytes.Buffer
 
-		_, _ = buf.WriteRune('}')
+	_, _ = buf.WriteString("IN ")
+	if to.Concurrency != nil {
+		_, _ = buf.WriteString((*to.Concurrency).String())
+		_, _ = buf.WriteRune(' ')
 	}
+	if to.Concurrent {
+		_, _ = buf.WriteString("CONCURRENT ")
+	}
+	_, _ = buf.WriteString("TRANSACTIONS")
 
-	_, _ = buf.WriteRune(')')
+	if to.BatchSize != nil {
+		_, _ = buf.WriteString(" OF ")
+		_, _ = buf.WriteString((*to.BatchSize).String())
+		_, _ = buf.WriteString(" ROWS")
+	}
+
+	switch to.OnError {
+	case OnErrorContinue:
+		_, _ = buf.WriteString(" ON ERROR CONTINUE")
+	case OnErrorBreak:
+		_, _ = buf.WriteString(" ON ERROR BREAK")
+	case OnErrorFail:
+		_, _ = buf.WriteString(" ON ERROR FAIL")
+	}
+
+	if to.ReportStatus != nil {
+		_, _ = buf.WriteString(" REPORT STATUS AS ")
+		_, _ = buf.WriteString(to.ReportStatus.String())
+	}
 
 	return buf.String()
 }
 
-// EdgePattern ...
-type EdgePattern struct {
-	Variable   *string
-	Labels     []string
-	Properties map[string]Expr
-	MinHops    *int
-	MaxHops    *int
-	Direction  EdgeDirection
+// OnErrorMode ...
+type OnErrorMode int
+
+const (
+	// OnErrorUndefined is used when no ON ERROR is given, which fails.
+	OnErrorUndefined OnErrorMode = iota
+	// OnErrorContinue ignores the failed transaction and runs the next ones.
+	OnErrorContinue
+	// OnErrorBreak ignores the failed transaction and stops running new ones.
+	OnErrorBreak
+	// OnErrorFail fails the whole query.
+	OnErrorFail
+)
+
+// YieldItem is a procedure result field, optionally renamed with `AS alias`.
+type YieldItem struct {
+	Field string
+	Alias *Variable
 }
 
-// Var ...
-func (ep EdgePattern) Var() *string {
-	return ep.Variable
+func (yi YieldItem) String() string {
+	if yi.Alias != nil {
+		return quoteIdent(yi.Field) + " AS " + yi.Alias.String()
+	}
+	return quoteIdent(yi.Field)
 }
 
-func (ep EdgePattern) String() string {
-	var buf bytes.Buffer
+// UpdatingClause ...
+type UpdatingClause struct {
+	Create  *CreateClause
+	Merge   *MergeClause
+	Set     *SetClause
+	Remove  *RemoveClause
+	Delete  *DeleteClause
+	Foreach *ForeachClause
+}
 
-	switch ep.Direction {
-	case EdgeRight, EdgeUndefined:
-		_, _ = buf.WriteRune('-')
-	case EdgeLeft, EdgeOutgoing:
-		_, _ = buf.WriteString("<-")
+func (uc UpdatingClause) String() string {
+	switch {
+	case uc.Create != nil:
+		return uc.Create.String()
+	case uc.Merge != nil:
+		return uc.Merge.String()
+	case uc.Set != nil:
+		return uc.Set.String()
+	case uc.Remove != nil:
+		return uc.Remove.String()
+	case uc.Delete != nil:
+		return uc.Delete.String()
+	case uc.Foreach != nil:
+		return uc.Foreach.String()
 	}
+	return ""
+}
 
-	_, _ = buf.WriteRune('[')
+// ForeachClause runs updating clauses for each element of a list, e.g.
+// `FOREACH (x IN list | SET x.flag = true)`.
+type ForeachClause struct {
+	Variable Variable
+	List     Expr
+	Updating []UpdatingClause
+}
+
+func (fc ForeachClause) String() string {
+	var buf bytes.Buffer
 
-	if ep.Variable != nil {
-		_, _ = buf.WriteString(*ep.Variable)
+	_, _ = buf.WriteString("FOREACH (")
+	_, _ = buf.WriteString(fc.Variable.String())
+	_, _ = buf.WriteString(" IN ")
+	_, _ = buf.WriteString(fc.List.String())
+	_, _ = buf.WriteString(" |")
+	for _, u := range fc.Updating {
+		_, _ = buf.WriteRune(' ')
+		_, _ = buf.WriteString(u.String())
 	}
+	_, _ = buf.WriteRune(')')
+
+	return buf.String()
+}
+
+// CreateClause creates the nodes and edges of the patterns.
+type CreateClause struct {
+	Pattern []MatchPattern
+}
+
+func (cc CreateClause) String() string {
+	var buf bytes.Buffer
 
-	for i, l := range ep.Labels {
+	_, _ = buf.WriteString("CREATE ")
+	for i, p := range cc.Pattern {
 		if i > 0 {
-			_, _ = buf.WriteString(" | ")
+			_, _ = buf.WriteString(", ")
 		}
-		_, _ = buf.WriteRune(':')
-		_, _ = buf.WriteString(l)
+		_, _ = buf.WriteString(p.String())
 	}
 
-	if len(ep.Properties) > 0 {
-		_, _ = buf.WriteRune('{')
+	return buf.String()
+}
 
-		var next bool
-		for p, v := range ep.Properties {
-			if next {
-				_, _ = buf.WriteRune(',')
-			}
-			_, _ = buf.WriteString(p)
-			_, _ = buf.WriteRune(':')
-			_, _ = buf.WriteString(v.String())
-			next = true
-		}
+// MergeClause matches the pattern or creates it when it doesn't exist.
+type MergeClause struct {
+	Pattern MatchPattern
+	Actions []MergeAction
+}
+
+func (mc MergeClause) String() string {
+	var buf bytes.Buffer
 
-		_, _ = buf.WriteRune('}')
+	_, _ = buf.WriteString("MERGE ")
+	_, _ = buf.WriteString(mc.Pattern.String())
+	for _, a := range mc.Actions {
+		_, _ = buf.WriteRune(' ')
+		_, _ = buf.WriteString(a.String())
 	}
 
-	_, _ = buf.WriteRune(']')
+	return buf.String()
+}
 
-	switch ep.Direction {
-	case EdgeLeft, EdgeUndefined:
-		_, _ = buf.WriteRune('-')
-	case EdgeRight, EdgeOutgoing:
-		_, _ = buf.WriteString("->")
+// MergeAction is an `ON CREATE SET` or `ON MATCH SET` of a MergeClause.
+type MergeAction struct {
+	OnMatch bool
+	Set     SetClause
+}
+
+func (ma MergeAction) String() string {
+	if ma.OnMatch {
+		return "ON MATCH " + ma.Set.String()
 	}
+	return "ON CREATE " + ma.Set.String()
+}
 
-	return buf.String()
+// SetClause ...
+type SetClause struct {
+	Items []SetItem
 }
 
-// EdgeDirection ...
-type EdgeDirection int
+func (sc SetClause) String() string {
+	var buf bytes.Buffer
 
-const (
-	EdgeUndefined EdgeDirection = iota
-	EdgeRight
-	EdgeLeft
-	EdgeOutgoing
-)
+	_, _ = buf.WriteString("SET ")
+	for i, item := range sc.Items {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString(item.String())
+	}
 
-// OrderDirection ...
-type OrderDirection int
+	return buf.String()
+}
 
-const (
-	// Ascending defines the ascending ordering.
-	Ascending OrderDirection = iota
-	// Descending defines the descending ordering.
-	Descending
-)
+// SetItem updates a property (`n.prop = value`), all properties of a
+// variable (`n = map`, `n += map`) or adds labels to a node (`n:Label`).
+type SetItem struct {
+	// Target is either a Variable or a PropertyAccess.
+	Target Expr
+	// Op is EQ or INC when a Value is set.
+	Op     Token
+	Value  Expr
+	Labels []string
+}
 
-// OrderBy ...
-type OrderBy struct {
-	Dir  OrderDirection
-	Item Expr
+func (si SetItem) String() string {
+	if len(si.Labels) > 0 {
+		return si.Target.String() + labelsString(si.Labels)
+	}
+	return si.Target.String() + " " + si.Op.String() + " " + si.Value.String()
 }
 
-func (o OrderBy) String() string {
-	return ""
+// RemoveClause ...
+type RemoveClause struct {
+	Items []RemoveItem
 }
 
-// Variable ...
-type Variable string
+func (rc RemoveClause) String() string {
+	var buf bytes.Buffer
 
-func (v Variable) String() string {
-	return string(v)
+	_, _ = buf.WriteString("REMOVE ")
+	for i, item := range rc.Items {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString(item.String())
+	}
+
+	return buf.String()
 }
 
-// Symbol ...
-type Symbol string
+// RemoveItem removes a property (`n.prop`) or labels from a node (`n:Label`).
+type RemoveItem struct {
+	// Target is a PropertyAccess, or the Variable the labels are removed from.
+	Target Expr
+	Labels []string
+}
 
-func (s Symbol) String() string {
-	return string(s)
+func (ri RemoveItem) String() string {
+	return ri.Target.String() + labelsString(ri.Labels)
 }
 
-// StrLiteral ...
-type StrLiteral string
+// labelsString renders labels as `:A:B`.
+func labelsString(labels []string) string {
+	var buf bytes.Buffer
+	for _, l := range labels {
+		_, _ = buf.WriteRune(':')
+		_, _ = buf.WriteString(quoteIdent(l))
+	}
+	return buf.String()
+}
 
-func (s StrLiteral) String() string {
-	return fmt.Sprintf("\"%s\"", string(s))
+// DeleteClause ...
+type DeleteClause struct {
+	Detach bool
+	Exprs  []Expr
 }
 
-// Expr ...
-type Expr interface {
-	exp()
-	String() string
+func (dc DeleteClause) String() string {
+	var buf bytes.Buffer
+
+	if dc.Detach {
+		_, _ = buf.WriteString("DETACH ")
+	}
+	_, _ = buf.WriteString("DELETE ")
+	for i, e := range dc.Exprs {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString(e.String())
+	}
+
+	return buf.String()
+}
+
+// MatchPattern ...
+type MatchPattern struct {
+	Variable *Variable
+	// Selector restricts the matched paths to the shortest ones, if set.
+	Selector *PathSelector
+	Mode     PathMode
+	Elements []PatternElement
+}
+
+func (mp MatchPattern) String() string {
+	var buf bytes.Buffer
+
+	if mp.Variable != nil {
+		_, _ = buf.WriteString((*mp.Variable).String())
+		_, _ = buf.WriteString(" = ")
+	}
+
+	isFunc := mp.Selector != nil && mp.Selector.isFunction()
+	if mp.Selector != nil {
+		_, _ = buf.WriteString(mp.Selector.String())
+		if isFunc {
+			_, _ = buf.WriteRune('(')
+		} else {
+			_, _ = buf.WriteRune(' ')
+		}
+	}
+
+	if mp.Mode != PathModeUndefined {
+		_, _ = buf.WriteString(mp.Mode.String())
+		_, _ = buf.WriteRune(' ')
+	}
+
+	for _, e := range mp.Elements {
+		_, _ = buf.WriteString(e.String())
+	}
+
+	if isFunc {
+		_, _ = buf.WriteRune(')')
+	}
+
+	return buf.String()
+}
+
+// PathMode restricts the repetition of nodes and relationships in the paths
+// matched by a pattern.
+type PathMode int
+
+const (
+	// PathModeUndefined is used when no path mode is given.
+	PathModeUndefined PathMode = iota
+	// PathModeWalk allows repeated nodes and relationships.
+	PathModeWalk
+	// PathModeTrail doesn't allow repeated relationships.
+	PathModeTrail
+	// PathModeAcyclic doesn't allow repeated nodes.
+	PathModeAcyclic
+)
+
+var pathModes = [...]string{
+	PathModeWalk:    "WALK",
+	PathModeTrail:   "TRAIL",
+	PathModeAcyclic: "ACYCLIC",
+}
+
+func (m PathMode) String() string {
+	if m >= 0 && int(m) < len(pathModes) {
+		return pathModes[m]
+	}
+	return ""
+}
+
+// PathSelectorKind is the kind of a PathSelector.
+type PathSelectorKind int
+
+const (
+	// ShortestPath is the `shortestPath(...)` function.
+	ShortestPath PathSelectorKind = iota
+	// AllShortestPaths is the `allShortestPaths(...)` function.
+	AllShortestPaths
+	// AnyShortest selects one of the shortest paths.
+	AnyShortest
+	// AllShortest selects all the shortest paths.
+	AllShortest
+	// ShortestK selects the Count shortest paths.
+	ShortestK
+	// ShortestKGroups selects all the paths of the Count shortest lengths.
+	ShortestKGroups
+)
+
+// PathSelector restricts the paths matched by a pattern to the shortest
+// ones, e.g. `shortestPath((a)-[*]-(b))` or `SHORTEST 2 (a)-[*]-(b)`.
+type PathSelector struct {
+	Kind PathSelectorKind
+	// Count is the k of `SHORTEST k` and `SHORTEST k GROUPS`.
+	Count int64
+}
+
+// isFunction returns true for the selectors written as a function wrapping
+// the pattern.
+func (ps PathSelector) isFunction() bool {
+	return ps.Kind == ShortestPath || ps.Kind == AllShortestPaths
+}
+
+func (ps PathSelector) String() string {
+	switch ps.Kind {
+	case ShortestPath:
+		return "shortestPath"
+	case AllShortestPaths:
+		return "allShortestPaths"
+	case AnyShortest:
+		return "ANY SHORTEST"
+	case AllShortest:
+		return "ALL SHORTEST"
+	case ShortestK:
+		return "SHORTEST " + strconv.FormatInt(ps.Count, 10)
+	case ShortestKGroups:
+		return "SHORTEST " + strconv.FormatInt(ps.Count, 10) + " GROUPS"
+	}
+	return ""
+}
+
+// PatternElement ...
+type PatternElement interface {
+	patternElem()
+	String() string
+}
+
+func (np NodePattern) patternElem()       {}
+func (ep EdgePattern) patternElem()       {}
+func (qp QuantifiedPattern) patternElem() {}
+
+// QuantifiedPattern represents a parenthesised sub-path, optionally filtered
+// with WHERE and repeated according to a quantifier, e.g.
+// `((a)-[:NEXT]->(b) WHERE a.x < b.x){1,5}`.
+type QuantifiedPattern struct {
+	Elements   []PatternElement
+	Where      Expr
+	Quantifier *PathQuantifier
+}
+
+func (qp QuantifiedPattern) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteRune('(')
+	for _, e := range qp.Elements {
+		_, _ = buf.WriteString(e.String())
+	}
+	if qp.Where != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString(qp.Where.String())
+	}
+	_, _ = buf.WriteRune(')')
+	if qp.Quantifier != nil {
+		_, _ = buf.WriteString(qp.Quantifier.String())
+	}
+
+	return buf.String()
+}
+
+// PathQuantifier is the number of repetitions of a QuantifiedPattern, which
+// is unbounded when Min or Max is nil.
+type PathQuantifier struct {
+	Min *int
+	Max *int
+}
+
+func (pq PathQuantifier) String() string {
+	min, max := pq.Min, pq.Max
+	switch {
+	case min != nil && max != nil && *min == *max:
+		return fmt.Sprintf("{%d}", *min)
+	case min != nil && max == nil && *min == 0:
+		return "*"
+	case min != nil && max == nil && *min == 1:
+		return "+"
+	}
+
+	var buf bytes.Buffer
+	_, _ = buf.WriteRune('{')
+	if min != nil {
+		_, _ = buf.WriteString(strconv.Itoa(*min))
+	}
+	_, _ = buf.WriteRune(',')
+	if max != nil {
+		_, _ = buf.WriteString(strconv.Itoa(*max))
+	}
+	_, _ = buf.WriteRune('}')
+
+	return buf.String()
+}
+
+// NodePattern ...
+type NodePattern struct {
+	Variable   *Variable
+	Labels     LabelExpr
+	Properties map[string]Expr
+	// PropertiesParam is set when the properties are given as a parameter.
+	PropertiesParam *Parameter
+}
+
+func (np NodePattern) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteRune('(')
+
+	if np.Variable != nil {
+		_, _ = buf.WriteString((*np.Variable).String())
+	}
+
+	if names, ok := FlattenLabels(np.Labels); ok {
+		for _, l := range names {
+			_, _ = buf.WriteString(" :")
+			_, _ = buf.WriteString(quoteIdent(l))
+		}
+	} else if np.Labels != nil {
+		_, _ = buf.WriteString(" :")
+		_, _ = buf.WriteString(np.Labels.String())
+	}
+
+	if props := propertiesString(np.Properties, np.PropertiesParam); props != "" {
+		_, _ = buf.WriteRune(' ')
+		_, _ = buf.WriteString(props)
+	}
+
+	_, _ = buf.WriteRune(')')
+
+	return buf.String()
+}
+
+// EdgePattern ...
+type EdgePattern struct {
+	Variable   *string
+	Labels     LabelExpr
+	Properties map[string]Expr
+	// PropertiesParam is set when the properties are given as a parameter.
+	PropertiesParam *Parameter
+	// VarLength is set for variable length edges (`*`), the hops are
+	// bounded by MinHops and MaxHops when given.
+	VarLength bool
+	MinHops   *int
+	MaxHops   *int
+	Direction EdgeDirection
+}
+
+// Var ...
+func (ep EdgePattern) Var() *string {
+	return ep.Variable
+}
+
+func (ep EdgePattern) String() string {
+	var buf bytes.Buffer
+
+	switch ep.Direction {
+	case EdgeRight, EdgeUndefined:
+		_, _ = buf.WriteRune('-')
+	case EdgeLeft, EdgeOutgoing:
+		_, _ = buf.WriteString("<-")
+	}
+
+	if ep.hasDetail() {
+		_, _ = buf.WriteRune('[')
+		start := buf.Len()
+
+		if ep.Variable != nil {
+			_, _ = buf.WriteString(*ep.Variable)
+		}
+
+		if ep.Labels != nil {
+			_, _ = buf.WriteRune(':')
+			_, _ = buf.WriteString(ep.Labels.String())
+		}
+
+		if ep.VarLength {
+			_, _ = buf.WriteString(ep.hopsString())
+		}
+
+		if props := propertiesString(ep.Properties, ep.PropertiesParam); props != "" {
+			if buf.Len() > start {
+				_, _ = buf.WriteRune(' ')
+			}
+			_, _ = buf.WriteString(props)
+		}
+
+		_, _ = buf.WriteRune(']')
+	}
+
+	switch ep.Direction {
+	case EdgeLeft, EdgeUndefined:
+		_, _ = buf.WriteRune('-')
+	case EdgeRight, EdgeOutgoing:
+		_, _ = buf.WriteString("->")
+	}
+
+	return buf.String()
+}
+
+// LabelExpr is a label expression of a node, or the relationship types of an
+// edge, e.g. `Person&!Banned` or `KNOWS|FOLLOWS`.
+type LabelExpr interface {
+	labelExpr()
+	String() string
+}
+
+func (ln LabelName) labelExpr()     {}
+func (lw LabelWildcard) labelExpr() {}
+func (ln LabelNot) labelExpr()      {}
+func (la LabelAnd) labelExpr()      {}
+func (lo LabelOr) labelExpr()       {}
+func (lg LabelGroup) labelExpr()    {}
+
+// LabelName matches a single label or relationship type.
+type LabelName string
+
+func (ln LabelName) String() string { return quoteIdent(string(ln)) }
+
+// LabelWildcard matches any label, `%`.
+type LabelWildcard struct{}
+
+func (lw LabelWildcard) String() string { return "%" }
+
+// LabelNot matches when the expression doesn't, e.g. `!Banned`.
+type LabelNot struct {
+	Expr LabelExpr
+}
+
+func (ln LabelNot) String() string { return "!" + parenLabel(ln.Expr, labelNotPrecedence) }
+
+// LabelAnd matches when both expressions match, e.g. `Person&Admin`.
+type LabelAnd struct {
+	LHS LabelExpr
+	RHS LabelExpr
+}
+
+func (la LabelAnd) String() string {
+	return parenLabel(la.LHS, labelAndPrecedence) + "&" + parenLabel(la.RHS, labelAndPrecedence+1)
+}
+
+// LabelOr matches when either expression matches, e.g. `Person|Robot`.
+type LabelOr struct {
+	LHS LabelExpr
+	RHS LabelExpr
+}
+
+func (lo LabelOr) String() string {
+	return parenLabel(lo.LHS, labelOrPrecedence) + "|" + parenLabel(lo.RHS, labelOrPrecedence+1)
+}
+
+// LabelGroup is a parenthesised label expression, e.g. `(A|B)&C`.
+type LabelGroup struct {
+	Expr LabelExpr
+}
+
+func (lg LabelGroup) String() string { return "(" + lg.Expr.String() + ")" }
+
+const (
+	labelOrPrecedence = iota + 1
+	labelAndPrecedence
+	labelNotPrecedence
+	labelAtomPrecedence
+)
+
+// labelPrecedence returns how strong a label expression binds when rendered.
+func labelPrecedence(e LabelExpr) int {
+	switch e.(type) {
+	case LabelOr:
+		return labelOrPrecedence
+	case LabelAnd:
+		return labelAndPrecedence
+	case LabelNot:
+		return labelNotPrecedence
+	}
+	return labelAtomPrecedence
+}
+
+// parenLabel renders e, in parens if it binds weaker than prec.
+func parenLabel(e LabelExpr, prec int) string {
+	if labelPrecedence(e) < prec {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+// FlattenLabels returns the names of a label expression that is a simple
+// conjunction, e.g. `:A:B` or `:A&B`. It returns false for other
+// expressions.
+func FlattenLabels(e LabelExpr) ([]string, bool) {
+	switch e := e.(type) {
+	case LabelName:
+		return []string{string(e)}, true
+	case LabelGroup:
+		return FlattenLabels(e.Expr)
+	case LabelAnd:
+		lhs, ok := FlattenLabels(e.LHS)
+		if !ok {
+			return nil, false
+		}
+		rhs, ok := FlattenLabels(e.RHS)
+		if !ok {
+			return nil, false
+		}
+		return append(lhs, rhs...), true
+	}
+	return nil, false
+}
+
+// FlattenTypes returns the names of a relationship type expression that is
+// a simple disjunction, e.g. `:A|B`. It returns false for other expressions.
+func FlattenTypes(e LabelExpr) ([]string, bool) {
+	switch e := e.(type) {
+	case LabelName:
+		return []string{string(e)}, true
+	case LabelGroup:
+		return FlattenTypes(e.Expr)
+	case LabelOr:
+		lhs, ok := FlattenTypes(e.LHS)
+		if !ok {
+			return nil, false
+		}
+		rhs, ok := FlattenTypes(e.RHS)
+		if !ok {
+			return nil, false
+		}
+		return append(lhs, rhs...), true
+	}
+	return nil, false
+}
+
+// hasDetail returns true if the edge needs the bracketed form to be rendered.
+func (ep EdgePattern) hasDetail() bool {
+	return ep.Variable != nil || ep.Labels != nil || ep.VarLength ||
+		len(ep.Properties) > 0 || ep.PropertiesParam != nil
+}
+
+// hopsString returns the range of a variable length edge, e.g. `*2..5`.
+func (ep EdgePattern) hopsString() string {
+	min, max := ep.MinHops, ep.MaxHops
+	switch {
+	case min != nil && max != nil && *min == *max:
+		return fmt.Sprintf("*%d", *min)
+	case min != nil && max != nil:
+		return fmt.Sprintf("*%d..%d", *min, *max)
+	case min != nil:
+		return fmt.Sprintf("*%d..", *min)
+	case max != nil:
+		return fmt.Sprintf("*..%d", *max)
+	}
+	return "*"
+}
+
+// propertiesString renders the properties of a node or edge, keys are
+// sorted so the output is stable.
+func propertiesString(props map[string]Expr, param *Parameter) string {
+	if param != nil {
+		return param.String()
+	} else if len(props) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	_, _ = buf.WriteRune('{')
+	for i, k := range keys {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString(quoteIdent(k))
+		_, _ = buf.WriteString(": ")
+		_, _ = buf.WriteString(props[k].String())
+	}
+	_, _ = buf.WriteRune('}')
+
+	return buf.String()
+}
+
+// quoteIdent returns the identifier quoted with backticks if it cannot be used bare.
+func quoteIdent(s string) string {
+	if isBareIdent(s) && Lookup(s) == IDENT {
+		return s
+	}
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "`" + strings.Replace(s, "`", "\\`", -1) + "`"
+}
+
+// quoteKey quotes a name that follows a dot, keywords need no quotes there.
+func quoteKey(s string) string {
+	if isBareIdent(s) {
+		return s
+	}
+	return quoteIdent(s)
+}
+
+// isBareIdent returns true if the string is a valid unquoted identifier.
+func isBareIdent(s string) bool {
+	for i, ch := range s {
+		if i == 0 && !isIdentFirstChar(ch) || !isIdentChar(ch) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// EdgeDirection ...
+type EdgeDirection int
+
+const (
+	// EdgeUndefined is an edge without arrow heads, `--`.
+	EdgeUndefined EdgeDirection = iota
+	// EdgeRight points to the right node, `-->`.
+	EdgeRight
+	// EdgeLeft points to the left node, `<--`.
+	EdgeLeft
+	// EdgeOutgoing has arrow heads on both sides, `<-->`.
+	EdgeOutgoing
+)
+
+// OrderDirection ...
+type OrderDirection int
+
+const (
+	// Ascending defines the ascending ordering.
+	Ascending OrderDirection = iota
+	// Descending defines the descending ordering.
+	Descending
+)
+
+// OrderBy ...
+type OrderBy struct {
+	Dir  OrderDirection
+	Item Expr
+}
+
+func (o OrderBy) String() string {
+	if o.Dir == Descending {
+		return o.Item.String() + " DESC"
+	}
+	return o.Item.String()
+}
+
+// Variable ...
+type Variable string
+
+func (v Variable) String() string {
+	return string(v)
+}
+
+// Symbol ...
+type Symbol string
+
+func (s Symbol) String() string {
+	return string(s)
+}
+
+// StrLiteral ...
+type StrLiteral string
+
+func (s StrLiteral) String() string {
+	return fmt.Sprintf("\"%s\"", strEscaper.Replace(string(s)))
+}
+
+// strEscaper escapes the characters that cannot appear as is in a quoted string.
+var strEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// IntegerLiteral ...
+type IntegerLiteral int64
+
+func (i IntegerLiteral) String() string {
+	return strconv.FormatInt(int64(i), 10)
+}
+
+// FloatLiteral represents a floating point number. Text is the literal as
+// written in the query, so it's rendered back unchanged.
+type FloatLiteral struct {
+	Value float64
+	Text  string
+}
+
+func (f FloatLiteral) String() string {
+	if f.Text != "" {
+		return f.Text
+	}
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	// keep it a float when parsed back
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// BoolLiteral ...
+type BoolLiteral bool
+
+func (b BoolLiteral) String() string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// NullLiteral ...
+type NullLiteral struct{}
+
+func (n NullLiteral) String() string {
+	return "null"
+}
+
+// ListLiteral ...
+type ListLiteral []Expr
+
+func (l ListLiteral) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteRune('[')
+	for i, e := range l {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString(e.String())
+	}
+	_, _ = buf.WriteRune(']')
+
+	return buf.String()
+}
+
+// MapLiteral is a map keeping the keys in the order they are written.
+type MapLiteral []MapItem
+
+// MapItem is a single `key: value` pair of a MapLiteral.
+type MapItem struct {
+	Key   string
+	Value Expr
+}
+
+func (m MapLiteral) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteRune('{')
+	for i, item := range m {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString(quoteIdent(item.Key))
+		_, _ = buf.WriteString(": ")
+		_, _ = buf.WriteString(item.Value.String())
+	}
+	_, _ = buf.WriteRune('}')
+
+	return buf.String()
+}
+
+// MapProjection represents a map built from a variable, e.g.
+// `n {.name, friends: collect(f.name), .*}`.
+type MapProjection struct {
+	Variable Variable
+	Items    []MapProjectionItem
+}
+
+// MapProjectionItem is a single entry of a MapProjection. It's either a
+// property selector `.key`, a literal entry `key: value`, a variable
+// selector `var` or all the properties `.*`.
+type MapProjectionItem struct {
+	// Key is the selected property, or the key of a literal entry.
+	Key string
+	// Value is only set for literal entries.
+	Value         Expr
+	Variable      *Variable
+	AllProperties bool
+}
+
+func (mi MapProjectionItem) String() string {
+	switch {
+	case mi.AllProperties:
+		return ".*"
+	case mi.Variable != nil:
+		return mi.Variable.String()
+	case mi.Value != nil:
+		return quoteIdent(mi.Key) + ": " + mi.Value.String()
+	}
+	return "." + quoteKey(mi.Key)
+}
+
+func (mp MapProjection) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteString(mp.Variable.String())
+	_, _ = buf.WriteString(" {")
+	for i, item := range mp.Items {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString(item.String())
+	}
+	_, _ = buf.WriteRune('}')
+
+	return buf.String()
+}
+
+// PropertyAccess represents the lookup of a property, e.g. `n.name`.
+type PropertyAccess struct {
+	Expr Expr
+	Key  string
+}
+
+func (pa PropertyAccess) String() string {
+	return parenExpr(pa.Expr, atomPrecedence) + "." + quoteKey(pa.Key)
+}
+
+// FunctionCall represents the invocation of a function, e.g. `count(DISTINCT n)`.
+type FunctionCall struct {
+	Namespace []string
+	Name      string
+	Distinct  bool
+	// Star is set for `count(*)`.
+	Star bool
+	Args []Expr
+}
+
+// IsAggregate returns true if the function is a built-in aggregation function.
+func (fc FunctionCall) IsAggregate() bool {
+	return len(fc.Namespace) == 0 && IsAggregate(fc.Name)
+}
+
+func (fc FunctionCall) String() string {
+	var buf bytes.Buffer
+
+	if len(fc.Namespace) == 0 && strings.EqualFold(fc.Name, "exists") {
+		// EXISTS is a keyword, but the parser accepts it as a function name
+		_, _ = buf.WriteString(fc.Name)
+	} else {
+		_, _ = buf.WriteString(namespacedString(fc.Namespace, fc.Name))
+	}
+	_, _ = buf.WriteRune('(')
+	if fc.Distinct {
+		_, _ = buf.WriteString("DISTINCT ")
+	}
+	if fc.Star {
+		_, _ = buf.WriteRune('*')
+	}
+	for i, arg := range fc.Args {
+		if i > 0 {
+			_, _ = buf.WriteString(", ")
+		}
+		_, _ = buf.WriteString(arg.String())
+	}
+	_, _ = buf.WriteRune(')')
+
+	return buf.String()
+}
+
+// aggregateFunctions is the registry of the built-in aggregation functions,
+// by lower case name.
+var aggregateFunctions = map[string]bool{
+	"avg":            true,
+	"collect":        true,
+	"count":          true,
+	"max":            true,
+	"min":            true,
+	"percentilecont": true,
+	"percentiledisc": true,
+	"stdev":          true,
+	"stdevp":         true,
+	"sum":            true,
+}
+
+// IsAggregate returns true if name is a built-in aggregation function.
+// Function names are case insensitive.
+func IsAggregate(name string) bool {
+	return aggregateFunctions[strings.ToLower(name)]
+}
+
+// CaseExpr represents a CASE expression. The simple form compares the
+// Input to the WHEN values, the searched form has no Input and evaluates
+// the WHEN predicates.
+type CaseExpr struct {
+	Input Expr
+	Whens []CaseWhen
+	Else  Expr
+}
+
+// CaseWhen is a `WHEN ... THEN ...` alternative of a CaseExpr.
+type CaseWhen struct {
+	When Expr
+	Then Expr
+}
+
+func (c CaseExpr) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteString("CASE")
+	if c.Input != nil {
+		_, _ = buf.WriteRune(' ')
+		_, _ = buf.WriteString(c.Input.String())
+	}
+	for _, w := range c.Whens {
+		_, _ = buf.WriteString(" WHEN ")
+		_, _ = buf.WriteString(w.When.String())
+		_, _ = buf.WriteString(" THEN ")
+		_, _ = buf.WriteString(w.Then.String())
+	}
+	if c.Else != nil {
+		_, _ = buf.WriteString(" ELSE ")
+		_, _ = buf.WriteString(c.Else.String())
+	}
+	_, _ = buf.WriteString(" END")
+
+	return buf.String()
+}
+
+// ListComprehension represents building a list from the elements of another
+// list, e.g. `[x IN list WHERE x > 0 | x * 2]`. Where and Projection are
+// optional.
+type ListComprehension struct {
+	Variable   Variable
+	List       Expr
+	Where      Expr
+	Projection Expr
+}
+
+func (lc ListComprehension) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteRune('[')
+	writeFilter(&buf, lc.Variable, lc.List, lc.Where)
+	if lc.Projection != nil {
+		_, _ = buf.WriteString(" | ")
+		_, _ = buf.WriteString(lc.Projection.String())
+	}
+	_, _ = buf.WriteRune(']')
+
+	return buf.String()
+}
+
+// writeFilter writes `x IN list WHERE predicate` to buf.
+func writeFilter(buf *bytes.Buffer, v Variable, list, where Expr) {
+	_, _ = buf.WriteString(v.String())
+	_, _ = buf.WriteString(" IN ")
+	_, _ = buf.WriteString(list.String())
+	if where != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString(where.String())
+	}
+}
+
+// PatternComprehension represents building a list from the matches of a
+// pattern, e.g. `[(a)-->(b) WHERE b.age > 18 | b.name]`.
+type PatternComprehension struct {
+	Pattern    MatchPattern
+	Where      Expr
+	Projection Expr
+}
+
+func (pc PatternComprehension) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteRune('[')
+	_, _ = buf.WriteString(pc.Pattern.String())
+	if pc.Where != nil {
+		_, _ = buf.WriteString(" WHERE ")
+		_, _ = buf.WriteString(pc.Where.String())
+	}
+	_, _ = buf.WriteString(" | ")
+	_, _ = buf.WriteString(pc.Projection.String())
+	_, _ = buf.WriteRune(']')
+
+	return buf.String()
+}
+
+// PatternPredicate represents a relationship pattern used as a predicate,
+// e.g. `WHERE (a)-[:FOLLOWS]->(b)`.
+type PatternPredicate struct {
+	Pattern MatchPattern
+}
+
+func (pp PatternPredicate) String() string {
+	return pp.Pattern.String()
+}
+
+// Quantifier is the kind of a QuantifierExpr.
+type Quantifier int
+
+const (
+	// QuantifierAll holds if the predicate holds for all the elements.
+	QuantifierAll Quantifier = iota
+	// QuantifierAny holds if the predicate holds for at least one element.
+	QuantifierAny
+	// QuantifierNone holds if the predicate holds for no element.
+	QuantifierNone
+	// QuantifierSingle holds if the predicate holds for exactly one element.
+	QuantifierSingle
+)
+
+var quantifiers = [...]string{
+	QuantifierAll:    "all",
+	QuantifierAny:    "any",
+	QuantifierNone:   "none",
+	QuantifierSingle: "single",
+}
+
+func (q Quantifier) String() string {
+	if q >= 0 && int(q) < len(quantifiers) {
+		return quantifiers[q]
+	}
+	return ""
+}
+
+// QuantifierExpr represents a predicate over the elements of a list, e.g.
+// `any(x IN list WHERE x > 0)`.
+type QuantifierExpr struct {
+	Quantifier Quantifier
+	Variable   Variable
+	List       Expr
+	Where      Expr
+}
+
+func (qe QuantifierExpr) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteString(qe.Quantifier.String())
+	_, _ = buf.WriteRune('(')
+	writeFilter(&buf, qe.Variable, qe.List, qe.Where)
+	_, _ = buf.WriteRune(')')
+
+	return buf.String()
+}
+
+// ReduceExpr represents folding a list into a single value, e.g.
+// `reduce(acc = 0, x IN list | acc + x)`.
+type ReduceExpr struct {
+	Accumulator Variable
+	Init        Expr
+	Variable    Variable
+	List        Expr
+	Expr        Expr
+}
+
+func (re ReduceExpr) String() string {
+	return fmt.Sprintf("reduce(%s = %s, %s IN %s | %s)", re.Accumulator, re.Init, re.Variable, re.List, re.Expr)
+}
+
+// SubqueryKind is the kind of a SubqueryExpr.
+type SubqueryKind int
+
+const (
+	// SubqueryExists checks whether the subquery has any result.
+	SubqueryExists SubqueryKind = iota
+	// SubqueryCount counts the results of the subquery.
+	SubqueryCount
+	// SubqueryCollect builds a list from the results of the subquery.
+	SubqueryCollect
+)
+
+var subqueryKinds = [...]string{
+	SubqueryExists:  "EXISTS",
+	SubqueryCount:   "COUNT",
+	SubqueryCollect: "COLLECT",
+}
+
+func (k SubqueryKind) String() string {
+	if k >= 0 && int(k) < len(subqueryKinds) {
+		return subqueryKinds[k]
+	}
+	return ""
+}
+
+// SubqueryExpr represents an EXISTS, COUNT or COLLECT subquery, e.g.
+// `EXISTS { MATCH (n)-->(m) WHERE m.admin }`.
+type SubqueryExpr struct {
+	Kind SubqueryKind
+	// Pattern and Where are set for the short form holding only a pattern,
+	// e.g. `COUNT { (n)-->(m) WHERE m.admin }`.
+	Pattern []MatchPattern
+	Where   Expr
+	// Query is set when the subquery is a full query.
+	Query *Query
+}
+
+func (se SubqueryExpr) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteString(se.Kind.String())
+	_, _ = buf.WriteString(" { ")
+	if se.Query != nil {
+		_, _ = buf.WriteString(se.Query.String())
+	} else {
+		for i, mp := range se.Pattern {
+			if i > 0 {
+				_, _ = buf.WriteString(", ")
+			}
+			_, _ = buf.WriteString(mp.String())
+		}
+		if se.Where != nil {
+			_, _ = buf.WriteString(" WHERE ")
+			_, _ = buf.WriteString(se.Where.String())
+		}
+	}
+	_, _ = buf.WriteString(" }")
+
+	return buf.String()
+}
+
+// LabelPredicate represents checking the labels of a node or the type of a
+// relationship, e.g. `n:Person|Robot`.
+type LabelPredicate struct {
+	Expr   Expr
+	Labels LabelExpr
+}
+
+func (lp LabelPredicate) String() string {
+	if names, ok := FlattenLabels(lp.Labels); ok {
+		var buf bytes.Buffer
+		_, _ = buf.WriteString(parenExpr(lp.Expr, atomPrecedence))
+		for _, l := range names {
+			_, _ = buf.WriteRune(':')
+			_, _ = buf.WriteString(quoteIdent(l))
+		}
+		return buf.String()
+	}
+	return parenExpr(lp.Expr, atomPrecedence) + ":" + lp.Labels.String()
+}
+
+// IndexExpr represents the lookup of a list element or a map value, e.g.
+// `list[0]` or `map['key']`.
+type IndexExpr struct {
+	Expr  Expr
+	Index Expr
+}
+
+func (ie IndexExpr) String() string {
+	return parenExpr(ie.Expr, atomPrecedence) + "[" + ie.Index.String() + "]"
+}
+
+// SliceExpr represents a range of a list, e.g. `list[1..3]`. Both bounds
+// are optional.
+type SliceExpr struct {
+	Expr Expr
+	From Expr
+	To   Expr
+}
+
+func (se SliceExpr) String() string {
+	var buf bytes.Buffer
+
+	_, _ = buf.WriteString(parenExpr(se.Expr, atomPrecedence))
+	_, _ = buf.WriteRune('[')
+	if se.From != nil {
+		_, _ = buf.WriteString(se.From.String())
+	}
+	_, _ = buf.WriteString("..")
+	if se.To != nil {
+		_, _ = buf.WriteString(se.To.String())
+	}
+	_, _ = buf.WriteRune(']')
+
+	return buf.String()
+}
+
+// Parameter represents a query parameter, e.g. `$name` or `$0`.
+type Parameter string
+
+func (p Parameter) String() string {
+	name := string(p)
+	// unlike other identifiers parameters can start with a digit
+	if name == "" || strings.IndexFunc(name, func(ch rune) bool { return !isIdentChar(ch) }) >= 0 {
+		name = quoteIdent(name)
+	}
+	return "$" + name
+}
+
+// Expr ...
+type Expr interface {
+	exp()
+	String() string
+}
+
+// BinaryExpr represents an operation between two expressions.
+type BinaryExpr struct {
+	Op  Token
+	LHS Expr
+	RHS Expr
+}
+
+func (e BinaryExpr) String() string {
+	prec := e.Op.Precedence()
+	// operators are left associative, so only the right hand side needs
+	// parens for the same precedence
+	return fmt.Sprintf("%s %s %s", parenExpr(e.LHS, prec), e.Op, parenExpr(e.RHS, prec+1))
+}
+
+// UnaryExpr represents an operation on a single expression.
+type UnaryExpr struct {
+	Op   Token
+	Expr Expr
+}
+
+func (e UnaryExpr) String() string {
+	if e.Op == NOT {
+		return "NOT " + parenExpr(e.Expr, notPrecedence)
+	}
+	// avoid rendering consecutive signs as a single token, negative numbers
+	// are literals so they don't get parens for their precedence
+	s := parenExpr(e.Expr, unaryPrecedence+1)
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		s = "(" + s + ")"
+	}
+	return e.Op.String() + s
+}
+
+// StringPredicate represents a string comparison, e.g. `a STARTS WITH b`.
+// Op is one of STARTS, ENDS or CONTAINS.
+type StringPredicate struct {
+	Op  Token
+	LHS Expr
+	RHS Expr
+}
+
+func (sp StringPredicate) String() string {
+	op := sp.Op.String()
+	if sp.Op == STARTS || sp.Op == ENDS {
+		op += " WITH"
+	}
+	return fmt.Sprintf("%s %s %s", parenExpr(sp.LHS, predicatePrecedence), op, parenExpr(sp.RHS, predicatePrecedence+1))
+}
+
+// RegexMatch represents matching a string against a regular expression,
+// e.g. `name =~ 'Jo.*'`.
+type RegexMatch struct {
+	Expr    Expr
+	Pattern Expr
+}
+
+func (rm RegexMatch) String() string {
+	return fmt.Sprintf("%s =~ %s", parenExpr(rm.Expr, predicatePrecedence), parenExpr(rm.Pattern, predicatePrecedence+1))
+}
+
+// InExpr represents a list membership check, e.g. `x IN [1, 2, 3]`.
+type InExpr struct {
+	Expr Expr
+	List Expr
+}
+
+func (ie InExpr) String() string {
+	return fmt.Sprintf("%s IN %s", parenExpr(ie.Expr, predicatePrecedence), parenExpr(ie.List, predicatePrecedence+1))
+}
+
+// NullCheck represents an `IS NULL` or `IS NOT NULL` check.
+type NullCheck struct {
+	Expr Expr
+	Not  bool
+}
+
+func (nc NullCheck) String() string {
+	if nc.Not {
+		return parenExpr(nc.Expr, predicatePrecedence) + " IS NOT NULL"
+	}
+	return parenExpr(nc.Expr, predicatePrecedence) + " IS NULL"
+}
+
+// exprPrecedence returns how strong an expression binds when rendered.
+func exprPrecedence(e Expr) int {
+	switch e := e.(type) {
+	case BinaryExpr:
+		return e.Op.Precedence()
+	case UnaryExpr:
+		if e.Op == NOT {
+			return notPrecedence
+		}
+		return unaryPrecedence
+	case StringPredicate, RegexMatch, InExpr, NullCheck:
+		return predicatePrecedence
+	}
+	return atomPrecedence
+}
+
+// parenExpr renders the expression wrapped in parens if it binds weaker than prec.
+func parenExpr(e Expr, prec int) string {
+	if exprPrecedence(e) < prec {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
 }
 
-func (v Variable) exp()   {}
-func (s Symbol) exp()     {}
-func (s StrLiteral) exp() {}
+func (v Variable) exp()              {}
+func (s Symbol) exp()                {}
+func (s StrLiteral) exp()            {}
+func (p Parameter) exp()             {}
+func (i IntegerLiteral) exp()        {}
+func (f FloatLiteral) exp()          {}
+func (b BoolLiteral) exp()           {}
+func (n NullLiteral) exp()           {}
+func (l ListLiteral) exp()           {}
+func (m MapLiteral) exp()            {}
+func (pa PropertyAccess) exp()       {}
+func (c CaseExpr) exp()              {}
+func (fc FunctionCall) exp()         {}
+func (ie IndexExpr) exp()            {}
+func (se SliceExpr) exp()            {}
+func (e BinaryExpr) exp()            {}
+func (e UnaryExpr) exp()             {}
+func (sp StringPredicate) exp()      {}
+func (rm RegexMatch) exp()           {}
+func (ie InExpr) exp()               {}
+func (nc NullCheck) exp()            {}
+func (lc ListComprehension) exp()    {}
+func (pc PatternComprehension) exp() {}
+func (qe QuantifierExpr) exp()       {}
+func (re ReduceExpr) exp()           {}
+func (mp MapProjection) exp()        {}
+func (se SubqueryExpr) exp()         {}
+func (pp PatternPredicate) exp()     {}
+func (lp LabelPredicate) exp()       {}
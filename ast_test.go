@@ -13,7 +13,7 @@ func TestQueryToString(t *testing.T) {
 	user := cypher.Variable("user")
 	node := cypher.NodePattern{
 		Variable: &user,
-		Labels:   []string{"User"},
+		Labels:   cypher.LabelName("User"),
 		Properties: map[string]cypher.Expr{
 			"name": cypher.StrLiteral("Adam"),
 		},
@@ -24,8 +24,8 @@ func TestQueryToString(t *testing.T) {
 				{Elements: []cypher.PatternElement{node}},
 			}},
 		},
-		ReturnItems: []cypher.Expr{
-			user,
+		ReturnItems: []cypher.ProjectionItem{
+			{Expr: user},
 		},
 	}
 
@@ -34,3 +34,57 @@ func TestQueryToString(t *testing.T) {
 		t.Errorf("Did not generate correct query: \nExpected:\n\t%s\nGot:\n\t%s", strQuery, r)
 	}
 }
+
+func TestIsAggregate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		agg  bool
+	}{
+		{"count", true},
+		{"collect", true},
+		{"stDev", true},
+		{"percentileCont", true},
+		{"toLower", false},
+		{"apoc.coll.sum", false},
+	} {
+		if agg := cypher.IsAggregate(tc.name); agg != tc.agg {
+			t.Errorf("IsAggregate(%s) = %t, expected %t", tc.name, agg, tc.agg)
+		}
+	}
+}
+
+func TestFloatLiteralString(t *testing.T) {
+	for _, tc := range []struct {
+		lit cypher.FloatLiteral
+		out string
+	}{
+		{lit: cypher.FloatLiteral{Value: 1000, Text: "1e3"}, out: "1e3"},
+		{lit: cypher.FloatLiteral{Value: 1000}, out: "1000.0"},
+		{lit: cypher.FloatLiteral{Value: 1.5e-7}, out: "1.5e-07"},
+	} {
+		if s := tc.lit.String(); s != tc.out {
+			t.Errorf("%#v: expected %s, got %s", tc.lit, tc.out, s)
+		}
+	}
+}
+
+func TestLabelExprString(t *testing.T) {
+	a, b, c := cypher.LabelName("A"), cypher.LabelName("B"), cypher.LabelName("C")
+	for _, tc := range []struct {
+		expr cypher.LabelExpr
+		out  string
+	}{
+		{expr: cypher.LabelAnd{LHS: cypher.LabelOr{LHS: a, RHS: b}, RHS: c}, out: "(A|B)&C"},
+		{expr: cypher.LabelAnd{LHS: a, RHS: cypher.LabelOr{LHS: b, RHS: c}}, out: "A&(B|C)"},
+		{expr: cypher.LabelOr{LHS: cypher.LabelAnd{LHS: a, RHS: b}, RHS: c}, out: "A&B|C"},
+		{expr: cypher.LabelOr{LHS: a, RHS: cypher.LabelOr{LHS: b, RHS: c}}, out: "A|(B|C)"},
+		{expr: cypher.LabelNot{Expr: cypher.LabelAnd{LHS: a, RHS: b}}, out: "!(A&B)"},
+		{expr: cypher.LabelNot{Expr: cypher.LabelOr{LHS: a, RHS: cypher.LabelWildcard{}}}, out: "!(A|%)"},
+		{expr: cypher.LabelNot{Expr: cypher.LabelNot{Expr: a}}, out: "!!A"},
+		{expr: cypher.LabelAnd{LHS: cypher.LabelGroup{Expr: cypher.LabelOr{LHS: a, RHS: b}}, RHS: c}, out: "(A|B)&C"},
+	} {
+		if s := tc.expr.String(); s != tc.out {
+			t.Errorf("%#v: expected %s, got %s", tc.expr, tc.out, s)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+package cypher
+
+// MemoSize returns the number of token offsets the parser remembers for the
+// statement being parsed.
+func MemoSize(p *Parser) int { return len(p.noPredicate) + len(p.noComprehension) }
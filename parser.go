@@ -3,17 +3,31 @@ package cypher
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 )
 
 // Parser represents a Cypher parser.
 type Parser struct {
 	s *bufScanner
+
+	// params collects the parameters referenced by the query being parsed.
+	params []ParameterRef
+
+	// noPredicate and noComprehension hold the token offsets where a pattern
+	// predicate or a pattern comprehension didn't match in the statement being
+	// parsed, so nested brackets aren't tried over and over again.
+	noPredicate     map[int]bool
+	noComprehension map[int]bool
 }
 
 // NewParser returns a new instance of Parser.
 func NewParser(r io.Reader) *Parser {
-	return &Parser{s: newBufScanner(r)}
+	return &Parser{
+		s:               newBufScanner(r),
+		noPredicate:     make(map[int]bool),
+		noComprehension: make(map[int]bool),
+	}
 }
 
 // ParseQuery parses a query string and returns its AST representation.
@@ -21,7 +35,33 @@ func ParseQuery(s string) (Query, error) {
 	return NewParser(strings.NewReader(s)).ParseQuery()
 }
 
-// ParseQuery parses a Cypher string and returns a Query AST object.
+// ParseScript parses a string of semicolon separated statements.
+func ParseScript(s string) ([]Statement, error) {
+	p := NewParser(strings.NewReader(s))
+
+	var stmts []Statement
+	for {
+		stmt, err := p.Next()
+		if err == io.EOF {
+			return stmts, nil
+		} else if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, *stmt)
+	}
+}
+
+// Statement is a query of a script along with its source span. End is the
+// position of the terminating semicolon or of the end of the input.
+type Statement struct {
+	Query Query
+	Start Pos
+	End   Pos
+}
+
+// ParseQuery parses a Cypher string and returns a Query AST object. When
+// the input holds several statements only the last one is returned, use
+// Next or ParseScript to get all of them.
 func (p *Parser) ParseQuery() (q Query, err error) {
 	for {
 		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == EOF {
@@ -30,33 +70,145 @@ func (p *Parser) ParseQuery() (q Query, err error) {
 			continue
 		} else {
 			p.Unscan()
-			q.Root, err = p.ParseSingleQuery()
-			if err != nil {
+			p.startStatement()
+			if q, err = p.parseUnionQuery(false); err != nil {
 				return q, err
 			}
+			q.params = p.params
+		}
+	}
+}
+
+// Next parses the next statement from the underlying reader, so scripts can
+// be parsed statement by statement. It returns io.EOF once the input is
+// consumed.
+func (p *Parser) Next() (*Statement, error) {
+	// skip empty statements
+	var start Pos
+	for {
+		tok, pos, _ := p.ScanIgnoreWhitespace()
+		if tok == EOF {
+			p.Unscan()
+			return nil, io.EOF
+		} else if tok != SEMICOLON {
+			p.Unscan()
+			start = pos
+			break
+		}
+	}
+
+	p.startStatement()
+	q, err := p.parseUnionQuery(false)
+	if err != nil {
+		return nil, err
+	}
+	q.params = p.params
+
+	tok, end, lit := p.ScanIgnoreWhitespace()
+	if tok == EOF {
+		p.Unscan()
+	} else if tok != SEMICOLON {
+		return nil, newParseError(tokstr(tok, lit), []string{";", "EOF"}, end)
+	}
+
+	return &Statement{Query: q, Start: start, End: end}, nil
+}
+
+// startStatement drops the state kept for the previous statement, the token
+// offsets remembered there are never looked at again.
+func (p *Parser) startStatement() {
+	p.params = nil
+	p.noPredicate = make(map[int]bool)
+	p.noComprehension = make(map[int]bool)
+}
+
+// parseUnionQuery parses single queries combined with UNION. Subqueries
+// checking for the existence of results may omit the RETURN.
+func (p *Parser) parseUnionQuery(optionalReturn bool) (q Query, err error) {
+	if q.Root, err = p.parseSingleQuery(optionalReturn); err != nil {
+		return q, err
+	}
+
+	for {
+		tok, pos, _ := p.ScanIgnoreWhitespace()
+		if tok != UNION {
+			p.Unscan()
+			return q, nil
+		}
+
+		var part UnionPart
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == ALL {
+			part.All = true
+		} else {
+			p.Unscan()
+		}
+		if part.Query, err = p.parseSingleQuery(optionalReturn); err != nil {
+			return q, err
+		}
+
+		if !sameColumns(q.Root, part.Query) {
+			return q, &ParseError{Message: "all sub queries in an UNION must have the same return column names", Pos: pos}
+		}
+		q.Union = append(q.Union, part)
+	}
+}
+
+// sameColumns returns true if both queries return the same column names.
+// Queries returning `*` are not checked, as the columns depend on the
+// variables in scope.
+func sameColumns(a, b *SingleQuery) bool {
+	if a.ReturnAll || b.ReturnAll {
+		return a.HasReturn() && b.HasReturn()
+	}
+	if len(a.ReturnItems) != len(b.ReturnItems) {
+		return false
+	}
+	for i := range a.ReturnItems {
+		if a.ReturnItems[i].Name() != b.ReturnItems[i].Name() {
+			return false
 		}
 	}
+	return true
 }
 
 // ParseSingleQuery ...
 func (p *Parser) ParseSingleQuery() (*SingleQuery, error) {
-	// read all MATCH clauses
+	return p.parseSingleQuery(false)
+}
+
+func (p *Parser) parseSingleQuery(optionalReturn bool) (*SingleQuery, error) {
 	sq := &SingleQuery{}
 	for {
-		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != MATCH && tok != OPTIONAL {
+		reading, err := p.scanReadingClauses()
+		if err != nil {
+			return nil, err
+		}
+		updating, err := p.scanUpdatingClauses()
+		if err != nil {
+			return nil, err
+		}
+
+		// a WITH ends the current part of a multi-part query
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != WITH {
 			p.Unscan()
+			sq.Reading = reading
+			sq.Updating = updating
 			break
-		} else {
-			p.Unscan()
-			r, err := p.ScanReadingClause()
-			if err != nil {
-				return nil, err
-			}
-			sq.Reading = append(sq.Reading, *r)
 		}
+		with, err := p.ScanWithClause()
+		if err != nil {
+			return nil, err
+		}
+		sq.Parts = append(sq.Parts, QueryPart{Reading: reading, Updating: updating, With: *with})
 	}
-	// scan return, if not it's an error because RETURN is obligatory
+
+	// scan return, it's only optional if the query updates the graph or
+	// ends calling a procedure
 	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RETURN {
+		if optionalReturn || len(sq.Updating) > 0 || endsWithCall(sq) {
+			p.Unscan()
+			return sq, nil
+		}
 		return nil, newParseError(tokstr(tok, lit), []string{"RETURN"}, pos)
 	}
 
@@ -67,198 +219,2108 @@ func (p *Parser) ParseSingleQuery() (*SingleQuery, error) {
 		p.Unscan()
 	}
 
+	var err error
+	if sq.ReturnAll, sq.ReturnItems, err = p.scanProjectionItems(); err != nil {
+		return nil, err
+	}
+	if sq.Order, err = p.scanOrderBy(); err != nil {
+		return nil, err
+	}
+	if sq.Skip, err = p.scanOptionalExpr(SKIP); err != nil {
+		return nil, err
+	}
+	if sq.Limit, err = p.scanOptionalExpr(LIMIT); err != nil {
+		return nil, err
+	}
+
 	return sq, nil
 }
 
-// ScanReadingClause ...
-func (p *Parser) ScanReadingClause() (*ReadingClause, error) {
-	rc := &ReadingClause{}
-
-	// might be optionally matching this
-	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == OPTIONAL {
-		rc.OptionalMatch = true
-	} else {
-		p.Unscan()
+// endsWithCall returns true if the query can end with its last call, either
+// a procedure call without YIELD, a standalone procedure call or a subquery
+// that doesn't return.
+func endsWithCall(sq *SingleQuery) bool {
+	if len(sq.Reading) == 0 || len(sq.Updating) > 0 {
+		return false
 	}
-
-	// MATCH is obligatory here
-	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != MATCH {
-		return nil, newParseError(tokstr(tok, lit), []string{"MATCH"}, pos)
+	last := sq.Reading[len(sq.Reading)-1]
+	if last.Subquery != nil {
+		return !last.Subquery.Query.Root.HasReturn()
+	} else if last.Call == nil {
+		return false
 	}
+	standalone := len(sq.Parts) == 0 && len(sq.Reading) == 1
+	return standalone || !last.Call.YieldAll && len(last.Call.Yield) == 0
+}
 
+// scanReadingClauses consumes all the consecutive reading clauses.
+func (p *Parser) scanReadingClauses() ([]ReadingClause, error) {
+	var reading []ReadingClause
 	for {
-		mp, err := p.ScanMatchPattern()
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != MATCH && tok != OPTIONAL && tok != UNWIND && tok != CALL {
+			p.Unscan()
+			return reading, nil
+		}
+		p.Unscan()
+		r, err := p.ScanReadingClause()
 		if err != nil {
 			return nil, err
 		}
-		rc.Pattern = append(rc.Pattern, *mp)
-
-		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
-			p.Unscan()
-			break
-		}
+		reading = append(reading, *r)
 	}
+}
 
-	// might be optional WHERE
-	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == WHERE {
-		exp, err := p.ScanExpression()
+// scanUpdatingClauses consumes all the consecutive updating clauses.
+func (p *Parser) scanUpdatingClauses() ([]UpdatingClause, error) {
+	var updating []UpdatingClause
+	for {
+		uc, err := p.ScanUpdatingClause()
 		if err != nil {
 			return nil, err
+		} else if uc == nil {
+			return updating, nil
 		}
-		rc.Where = &exp
-	} else {
+		updating = append(updating, *uc)
+	}
+}
+
+// ScanUpdatingClause returns an UpdatingClause if the next token starts one.
+func (p *Parser) ScanUpdatingClause() (*UpdatingClause, error) {
+	uc := &UpdatingClause{}
+
+	var err error
+	switch tok, _, _ := p.ScanIgnoreWhitespace(); tok {
+	case CREATE:
+		uc.Create = &CreateClause{}
+		uc.Create.Pattern, err = p.scanMatchPatterns(false)
+	case MERGE:
+		uc.Merge, err = p.ScanMergeClause()
+	case SET:
+		uc.Set, err = p.ScanSetClause()
+	case REMOVE:
+		uc.Remove, err = p.ScanRemoveClause()
+	case DETACH:
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != DELETE {
+			return nil, newParseError(tokstr(tok, lit), []string{"DELETE"}, pos)
+		}
+		uc.Delete = &DeleteClause{Detach: true}
+		uc.Delete.Exprs, err = p.scanExpressions()
+	case DELETE:
+		uc.Delete = &DeleteClause{}
+		uc.Delete.Exprs, err = p.scanExpressions()
+	case FOREACH:
+		uc.Foreach, err = p.ScanForeachClause()
+	default:
 		p.Unscan()
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return rc, nil
+	return uc, nil
 }
 
-// ScanMatchPattern ...
-func (p *Parser) ScanMatchPattern() (*MatchPattern, error) {
-	mp := &MatchPattern{}
+// ScanForeachClause parses `(variable IN list | updates)`, after the FOREACH keyword.
+func (p *Parser) ScanForeachClause() (*ForeachClause, error) {
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != LPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+	}
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok != IDENT {
+		return nil, newParseError(tokstr(tok, lit), []string{"Variable"}, pos)
+	}
+	fc := &ForeachClause{Variable: Variable(lit)}
 
-	if tok, _, lit := p.ScanIgnoreWhitespace(); tok == IDENT {
-		// We need the `=` character here
-		if tok1, pos, lit1 := p.ScanIgnoreWhitespace(); tok1 != EQ {
-			return nil, newParseError(tokstr(tok1, lit1), []string{"="}, pos)
-		}
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != IN {
+		return nil, newParseError(tokstr(tok, lit), []string{"IN"}, pos)
+	}
+	list, err := p.ScanExpression()
+	if err != nil {
+		return nil, err
+	}
+	fc.List = list
 
-		v := Variable(lit)
-		mp.Variable = &v
-	} else {
-		p.Unscan()
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != BAR {
+		return nil, newParseError(tokstr(tok, lit), []string{"|"}, pos)
+	}
+	if fc.Updating, err = p.scanUpdatingClauses(); err != nil {
+		return nil, err
+	}
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
+		expected := []string{")"}
+		if len(fc.Updating) == 0 {
+			expected = []string{"CREATE", "MERGE", "SET", "REMOVE", "DELETE", "FOREACH"}
+		}
+		return nil, newParseError(tokstr(tok, lit), expected, pos)
 	}
 
-	// scan the pattern itself
-	elems, err := p.ScanPatternElements()
+	return fc, nil
+}
+
+// ScanMergeClause parses the pattern and actions of MERGE, after the MERGE keyword.
+func (p *Parser) ScanMergeClause() (*MergeClause, error) {
+	mp, err := p.scanMatchPattern(false)
 	if err != nil {
 		return nil, err
 	}
-	mp.Elements = elems
+	mc := &MergeClause{Pattern: *mp}
 
-	return mp, nil
+	for {
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != ON {
+			p.Unscan()
+			return mc, nil
+		}
+
+		var action MergeAction
+		switch tok, pos, lit := p.ScanIgnoreWhitespace(); tok {
+		case MATCH:
+			action.OnMatch = true
+		case CREATE:
+		default:
+			return nil, newParseError(tokstr(tok, lit), []string{"MATCH", "CREATE"}, pos)
+		}
+
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != SET {
+			return nil, newParseError(tokstr(tok, lit), []string{"SET"}, pos)
+		}
+		set, err := p.ScanSetClause()
+		if err != nil {
+			return nil, err
+		}
+		action.Set = *set
+		mc.Actions = append(mc.Actions, action)
+	}
 }
 
-// ScanPatternElements ...
-func (p *Parser) ScanPatternElements() (pe []PatternElement, err error) {
-	var node *NodePattern
-	numParens := 0
+// ScanSetClause parses the items of SET, after the SET keyword.
+func (p *Parser) ScanSetClause() (*SetClause, error) {
+	sc := &SetClause{}
 	for {
-		node, err = p.ScanNodePattern()
+		target, err := p.scanPropertyTarget()
 		if err != nil {
 			return nil, err
 		}
-		if node == nil {
-			// might be only parens around the actual match, lets try...
-			if tok, pos, lit := p.ScanIgnoreWhitespace(); tok == LPAREN {
-				numParens++
-			} else {
-				return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+		item := SetItem{Target: target}
+
+		switch tok, pos, lit := p.ScanIgnoreWhitespace(); {
+		case tok == COLON:
+			if _, ok := target.(Variable); !ok {
+				return nil, newParseError(tokstr(tok, lit), []string{"="}, pos)
 			}
-		} else {
-			break
+			p.Unscan()
+			if item.Labels, err = p.scanLabels(); err != nil {
+				return nil, err
+			}
+		case tok == EQ, tok == INC:
+			if _, ok := target.(Variable); !ok && tok == INC {
+				return nil, newParseError(tokstr(tok, lit), []string{"="}, pos)
+			}
+			item.Op = tok
+			if item.Value, err = p.ScanExpression(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, newParseError(tokstr(tok, lit), []string{"=", "+=", ":"}, pos)
 		}
-	}
+		sc.Items = append(sc.Items, item)
 
-	pe = []PatternElement{node}
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
+			p.Unscan()
+			return sc, nil
+		}
+	}
+}
 
+// ScanRemoveClause parses the items of REMOVE, after the REMOVE keyword.
+func (p *Parser) ScanRemoveClause() (*RemoveClause, error) {
+	rc := &RemoveClause{}
 	for {
-		edge, err := p.ScanEdgePattern()
+		target, err := p.scanPropertyTarget()
 		if err != nil {
 			return nil, err
-		} else if edge == nil {
-			break
-		} else {
-			pe = append(pe, edge)
 		}
-		node, err = p.ScanNodePattern()
+		item := RemoveItem{Target: target}
+
+		if _, ok := target.(Variable); ok {
+			if item.Labels, err = p.scanLabels(); err != nil {
+				return nil, err
+			} else if item.Labels == nil {
+				tok, pos, lit := p.ScanIgnoreWhitespace()
+				return nil, newParseError(tokstr(tok, lit), []string{":", "."}, pos)
+			}
+		}
+		rc.Items = append(rc.Items, item)
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
+			p.Unscan()
+			return rc, nil
+		}
+	}
+}
+
+// scanPropertyTarget consumes a variable optionally followed by property
+// lookups, e.g. `n` or `n.address.city`.
+func (p *Parser) scanPropertyTarget() (Expr, error) {
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok != IDENT {
+		return nil, newParseError(tokstr(tok, lit), []string{"Variable"}, pos)
+	}
+
+	var target Expr = Variable(lit)
+	for {
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != DOT {
+			p.Unscan()
+			return target, nil
+		}
+		key, err := p.scanNameAfterDot("Property Key")
 		if err != nil {
 			return nil, err
-		} else if node == nil {
-			break
-		} else {
-			pe = append(pe, node)
 		}
+		target = PropertyAccess{Expr: target, Key: key}
 	}
+}
 
-	// need to close all open parens
-	for i := 0; i < numParens; i++ {
-		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
-			return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+// scanLabels consumes consecutive `:Label` names.
+func (p *Parser) scanLabels() ([]string, error) {
+	var labels []string
+	for {
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COLON {
+			p.Unscan()
+			return labels, nil
 		}
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		if tok != IDENT {
+			return nil, newParseError(tokstr(tok, lit), []string{"Label Identifier"}, pos)
+		}
+		labels = append(labels, lit)
 	}
+}
 
-	return pe, nil
+// scanExpressions consumes a comma separated list of expressions.
+func (p *Parser) scanExpressions() ([]Expr, error) {
+	var exprs []Expr
+	for {
+		expr, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
+			p.Unscan()
+			return exprs, nil
+		}
+	}
 }
 
-// ScanNodePattern returns a NodePattern if possible to consume a complete valid node.
-func (p *Parser) ScanNodePattern() (*NodePattern, error) {
-	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != LPAREN {
-		// We already know we cannot consume a valid node if the pattern doesn't start with `(`
+// ScanWithClause parses the projection of a WITH clause, after the WITH keyword.
+func (p *Parser) ScanWithClause() (*WithClause, error) {
+	wc := &WithClause{}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == DISTINCT {
+		wc.Distinct = true
+	} else {
 		p.Unscan()
-		return nil, nil
 	}
-	var validNode bool
-	var node NodePattern
-	if tok, _, lit := p.ScanIgnoreWhitespace(); tok == IDENT {
-		v := Variable(lit)
-		node.Variable = &v
-		validNode = true
+
+	var err error
+	if wc.All, wc.Items, err = p.scanProjectionItems(); err != nil {
+		return nil, err
+	}
+	if wc.Order, err = p.scanOrderBy(); err != nil {
+		return nil, err
+	}
+	if wc.Skip, err = p.scanOptionalExpr(SKIP); err != nil {
+		return nil, err
+	}
+	if wc.Limit, err = p.scanOptionalExpr(LIMIT); err != nil {
+		return nil, err
+	}
+	if wc.Where, err = p.scanOptionalExpr(WHERE); err != nil {
+		return nil, err
+	}
+
+	return wc, nil
+}
+
+// scanProjectionItems consumes the items of RETURN or WITH. It reports
+// whether all variables are projected with `*`.
+func (p *Parser) scanProjectionItems() (all bool, items []ProjectionItem, err error) {
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == MUL {
+		all = true
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
+			p.Unscan()
+			return all, nil, nil
+		}
 	} else {
 		p.Unscan()
 	}
 
 	for {
-		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == COLON {
-			if tok1, pos, lit := p.ScanIgnoreWhitespace(); tok1 == IDENT {
-				node.Labels = append(node.Labels, lit)
-				validNode = true
-			} else {
-				return nil, newParseError(tokstr(tok, lit), []string{"Label Identifier"}, pos)
+		expr, err := p.ScanExpression()
+		if err != nil {
+			return false, nil, err
+		}
+		item := ProjectionItem{Expr: expr}
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == AS {
+			tok, pos, lit := p.ScanIgnoreWhitespace()
+			if tok != IDENT {
+				return false, nil, newParseError(tokstr(tok, lit), []string{"Variable"}, pos)
 			}
+			alias := Variable(lit)
+			item.Alias = &alias
 		} else {
 			p.Unscan()
-			break
 		}
-	}
+		items = append(items, item)
 
-	props, err := p.ScanProperties()
-	if err != nil {
-		return nil, err
-	} else if props != nil {
-		node.Properties = *props
-		validNode = true
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
+			p.Unscan()
+			return all, items, nil
+		}
 	}
+}
+
+// scanOrderBy consumes an optional `ORDER BY` with its sort items.
+func (p *Parser) scanOrderBy() ([]OrderBy, error) {
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != ORDER {
+		p.Unscan()
+		return nil, nil
+	}
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != BY {
+		return nil, newParseError(tokstr(tok, lit), []string{"BY"}, pos)
+	}
+
+	var order []OrderBy
+	for {
+		expr, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		o := OrderBy{Item: expr}
+
+		switch tok, _, _ := p.ScanIgnoreWhitespace(); tok {
+		case DESC, DESCENDING:
+			o.Dir = Descending
+		case ASC, ASCENDING:
+			o.Dir = Ascending
+		default:
+			p.Unscan()
+		}
+		order = append(order, o)
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
+			p.Unscan()
+			return order, nil
+		}
+	}
+}
+
+// scanOptionalExpr consumes an expression if it is preceded by the keyword.
+func (p *Parser) scanOptionalExpr(keyword Token) (*Expr, error) {
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != keyword {
+		p.Unscan()
+		return nil, nil
+	}
+	expr, err := p.ScanExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &expr, nil
+}
+
+// ScanReadingClause ...
+func (p *Parser) ScanReadingClause() (*ReadingClause, error) {
+	rc := &ReadingClause{}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == UNWIND {
+		uc, err := p.ScanUnwindClause()
+		if err != nil {
+			return nil, err
+		}
+		rc.Unwind = uc
+		return rc, nil
+	} else if tok == CALL {
+		// a subquery starts with a brace or its variables scope
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == LBRACE || tok == LPAREN {
+			p.Unscan()
+			sc, err := p.ScanSubqueryCall()
+			if err != nil {
+				return nil, err
+			}
+			rc.Subquery = sc
+			return rc, nil
+		}
+		p.Unscan()
+
+		cc, err := p.ScanCallClause()
+		if err != nil {
+			return nil, err
+		}
+		rc.Call = cc
+		return rc, nil
+	}
+	p.Unscan()
+
+	// might be optionally matching this
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == OPTIONAL {
+		rc.OptionalMatch = true
+	} else {
+		p.Unscan()
+	}
+
+	// MATCH is obligatory here
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != MATCH {
+		return nil, newParseError(tokstr(tok, lit), []string{"MATCH"}, pos)
+	}
+
+	var err error
+	if rc.Pattern, err = p.scanMatchPatterns(true); err != nil {
+		return nil, err
+	}
+
+	// might be optional WHERE
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == WHERE {
+		exp, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		rc.Where = &exp
+	} else {
+		p.Unscan()
+	}
+
+	return rc, nil
+}
+
+// ScanUnwindClause parses `expr AS variable`, after the UNWIND keyword.
+func (p *Parser) ScanUnwindClause() (*UnwindClause, error) {
+	expr, err := p.ScanExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != AS {
+		return nil, newParseError(tokstr(tok, lit), []string{"AS"}, pos)
+	}
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok != IDENT {
+		return nil, newParseError(tokstr(tok, lit), []string{"Variable"}, pos)
+	}
+
+	return &UnwindClause{Expr: expr, Variable: Variable(lit)}, nil
+}
+
+// scanMatchPatterns consumes a comma separated list of patterns.
+func (p *Parser) scanMatchPatterns(selector bool) ([]MatchPattern, error) {
+	var patterns []MatchPattern
+	for {
+		mp, err := p.scanMatchPattern(selector)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, *mp)
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
+			p.Unscan()
+			return patterns, nil
+		}
+	}
+}
+
+// ScanCallClause parses a procedure call, after the CALL keyword.
+func (p *Parser) ScanCallClause() (*CallClause, error) {
+	names, err := p.scanNamespacedName()
+	if err != nil {
+		return nil, err
+	}
+	cc := &CallClause{Namespace: names[:len(names)-1], Name: names[len(names)-1]}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == LPAREN {
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != RPAREN {
+			p.Unscan()
+			if cc.Args, err = p.scanExpressions(); err != nil {
+				return nil, err
+			}
+			if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
+				return nil, newParseError(tokstr(tok, lit), []string{",", ")"}, pos)
+			}
+		}
+	} else {
+		p.Unscan()
+		cc.ImplicitArgs = true
+	}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != YIELD {
+		p.Unscan()
+		return cc, nil
+	}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == MUL {
+		cc.YieldAll = true
+		return cc, nil
+	}
+	p.Unscan()
+
+	for {
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		if tok != IDENT {
+			return nil, newParseError(tokstr(tok, lit), []string{"Field"}, pos)
+		}
+		item := YieldItem{Field: lit}
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == AS {
+			tok, pos, lit := p.ScanIgnoreWhitespace()
+			if tok != IDENT {
+				return nil, newParseError(tokstr(tok, lit), []string{"Variable"}, pos)
+			}
+			alias := Variable(lit)
+			item.Alias = &alias
+		} else {
+			p.Unscan()
+		}
+		cc.Yield = append(cc.Yield, item)
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
+			p.Unscan()
+			break
+		}
+	}
+
+	if cc.Where, err = p.scanOptionalExpr(WHERE); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// ScanSubqueryCall parses a `{ query }` subquery with its optional scope and
+// transactions, after the CALL keyword.
+func (p *Parser) ScanSubqueryCall() (*SubqueryCall, error) {
+	sc := &SubqueryCall{}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == LPAREN {
+		scope, err := p.scanSubqueryScope()
+		if err != nil {
+			return nil, err
+		}
+		sc.Scope = scope
+	} else {
+		p.Unscan()
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != LBRACE {
+		return nil, newParseError(tokstr(tok, lit), []string{"{"}, pos)
+	}
+	q, err := p.parseUnionQuery(false)
+	if err != nil {
+		return nil, err
+	}
+	sc.Query = q
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RBRACE {
+		return nil, newParseError(tokstr(tok, lit), []string{"}"}, pos)
+	}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != IN {
+		p.Unscan()
+		return sc, nil
+	}
+	if sc.InTransactions, err = p.scanInTransactions(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// scanSubqueryScope consumes the variables imported by a subquery, after the `(`.
+func (p *Parser) scanSubqueryScope() (*SubqueryScope, error) {
+	scope := &SubqueryScope{}
+
+	switch tok, _, _ := p.ScanIgnoreWhitespace(); tok {
+	case RPAREN:
+		return scope, nil
+	case MUL:
+		scope.All = true
+	default:
+		p.Unscan()
+		for {
+			tok, pos, lit := p.ScanIgnoreWhitespace()
+			if tok != IDENT {
+				return nil, newParseError(tokstr(tok, lit), []string{"Variable"}, pos)
+			}
+			scope.Variables = append(scope.Variables, Variable(lit))
+
+			if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COMMA {
+				p.Unscan()
+				break
+			}
+		}
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+	}
+	return scope, nil
+}
+
+// scanInTransactions consumes the options of a subquery run in separate
+// transactions, after the IN keyword.
+func (p *Parser) scanInTransactions() (*TransactionOptions, error) {
+	opts := &TransactionOptions{}
+
+	// optional `n CONCURRENT` before TRANSACTIONS
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if isContextualKeyword(tok, lit, "CONCURRENT") {
+		opts.Concurrent = true
+	} else if !isContextualKeyword(tok, lit, "TRANSACTIONS") {
+		p.Unscan()
+		expr, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		opts.Concurrent = true
+		opts.Concurrency = &expr
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); !isContextualKeyword(tok, lit, "CONCURRENT") {
+			return nil, newParseError(tokstr(tok, lit), []string{"CONCURRENT"}, pos)
+		}
+	} else {
+		p.Unscan()
+	}
+	if tok, pos, lit = p.ScanIgnoreWhitespace(); !isContextualKeyword(tok, lit, "TRANSACTIONS") {
+		return nil, newParseError(tokstr(tok, lit), []string{"TRANSACTIONS"}, pos)
+	}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == OF {
+		expr, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		opts.BatchSize = &expr
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); !isContextualKeyword(tok, lit, "ROWS") && !isContextualKeyword(tok, lit, "ROW") {
+			return nil, newParseError(tokstr(tok, lit), []string{"ROWS"}, pos)
+		}
+	} else {
+		p.Unscan()
+	}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == ON {
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); !isContextualKeyword(tok, lit, "ERROR") {
+			return nil, newParseError(tokstr(tok, lit), []string{"ERROR"}, pos)
+		}
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		switch {
+		case isContextualKeyword(tok, lit, "CONTINUE"):
+			opts.OnError = OnErrorContinue
+		case isContextualKeyword(tok, lit, "BREAK"):
+			opts.OnError = OnErrorBreak
+		case isContextualKeyword(tok, lit, "FAIL"):
+			opts.OnError = OnErrorFail
+		default:
+			return nil, newParseError(tokstr(tok, lit), []string{"CONTINUE", "BREAK", "FAIL"}, pos)
+		}
+	} else {
+		p.Unscan()
+	}
+
+	if tok, _, lit := p.ScanIgnoreWhitespace(); isContextualKeyword(tok, lit, "REPORT") {
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); !isContextualKeyword(tok, lit, "STATUS") {
+			return nil, newParseError(tokstr(tok, lit), []string{"STATUS"}, pos)
+		}
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != AS {
+			return nil, newParseError(tokstr(tok, lit), []string{"AS"}, pos)
+		}
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		if tok != IDENT {
+			return nil, newParseError(tokstr(tok, lit), []string{"Variable"}, pos)
+		}
+		v := Variable(lit)
+		opts.ReportStatus = &v
+	} else {
+		p.Unscan()
+	}
+
+	return opts, nil
+}
+
+// isContextualKeyword returns true if the identifier is the given word. These
+// words are only keywords in some clauses, so they are scanned as identifiers.
+func isContextualKeyword(tok Token, lit, word string) bool {
+	return tok == IDENT && strings.EqualFold(lit, word)
+}
+
+// scanNamespacedName consumes a dotted name, e.g. `db.index.fulltext.queryNodes`.
+// Keywords are accepted after a dot, as in `apoc.create.node`.
+func (p *Parser) scanNamespacedName() ([]string, error) {
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok != IDENT {
+		return nil, newParseError(tokstr(tok, lit), []string{"Name"}, pos)
+	}
+	names := []string{lit}
+
+	for {
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != DOT {
+			p.Unscan()
+			return names, nil
+		}
+		name, err := p.scanNameAfterDot("Name")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+}
+
+// scanNameAfterDot consumes the name following a dot, where keywords are
+// accepted as names too.
+func (p *Parser) scanNameAfterDot(expected string) (string, error) {
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok == IDENT || tok.isKeyword() {
+		return lit, nil
+	}
+	return "", newParseError(tokstr(tok, lit), []string{expected}, pos)
+}
+
+// ScanMatchPattern ...
+func (p *Parser) ScanMatchPattern() (*MatchPattern, error) {
+	return p.scanMatchPattern(true)
+}
+
+// scanMatchPattern parses a pattern, path selectors are only accepted if
+// selector is set since they mean nothing to CREATE and MERGE.
+func (p *Parser) scanMatchPattern(selector bool) (*MatchPattern, error) {
+	mp := &MatchPattern{}
+
+	// a name followed by `=` is the path variable, otherwise it might be
+	// the path selector
+	m := p.s.mark()
+	if tok, _, lit := p.ScanIgnoreWhitespace(); tok == IDENT {
+		if tok1, _, _ := p.ScanIgnoreWhitespace(); tok1 == EQ {
+			v := Variable(lit)
+			mp.Variable = &v
+			p.s.release()
+		} else {
+			p.s.reset(m)
+		}
+	} else {
+		p.s.reset(m)
+	}
+
+	_, pos, _ := p.ScanIgnoreWhitespace()
+	p.Unscan()
+	ps, err := p.scanPathSelector()
+	if err != nil {
+		return nil, err
+	} else if ps != nil && !selector {
+		return nil, &ParseError{Message: fmt.Sprintf("%s is only allowed in MATCH", ps), Pos: pos}
+	}
+	mp.Selector = ps
+
+	isFunc := ps != nil && ps.isFunction()
+	if !isFunc {
+		tok, _, lit := p.ScanIgnoreWhitespace()
+		switch {
+		case isContextualKeyword(tok, lit, "WALK"):
+			mp.Mode = PathModeWalk
+		case isContextualKeyword(tok, lit, "TRAIL"):
+			mp.Mode = PathModeTrail
+		case isContextualKeyword(tok, lit, "ACYCLIC"):
+			mp.Mode = PathModeAcyclic
+		default:
+			p.Unscan()
+		}
+	}
+
+	if isFunc {
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != LPAREN {
+			return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+		}
+	}
+
+	// scan the pattern itself
+	elems, err := p.ScanPatternElements()
+	if err != nil {
+		return nil, err
+	}
+	mp.Elements = elems
+
+	if isFunc {
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
+			return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+		}
+	}
+
+	return mp, nil
+}
+
+// scanPathSelector consumes the selector of the shortest paths, if any. The
+// GQL forms may name the paths, e.g. `ANY SHORTEST PATH`, which is dropped.
+func (p *Parser) scanPathSelector() (*PathSelector, error) {
+	tok, _, lit := p.ScanIgnoreWhitespace()
+	switch {
+	case isContextualKeyword(tok, lit, "shortestPath"):
+		return &PathSelector{Kind: ShortestPath}, nil
+	case isContextualKeyword(tok, lit, "allShortestPaths"):
+		return &PathSelector{Kind: AllShortestPaths}, nil
+	case tok == ALL, isContextualKeyword(tok, lit, "ANY"):
+		if tok1, pos, lit1 := p.ScanIgnoreWhitespace(); !isContextualKeyword(tok1, lit1, "SHORTEST") {
+			return nil, newParseError(tokstr(tok1, lit1), []string{"SHORTEST"}, pos)
+		}
+		p.scanPathsWord()
+		if tok == ALL {
+			return &PathSelector{Kind: AllShortest}, nil
+		}
+		return &PathSelector{Kind: AnyShortest}, nil
+	case isContextualKeyword(tok, lit, "SHORTEST"):
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		if tok != INTEGER {
+			return nil, newParseError(tokstr(tok, lit), []string{"Integer"}, pos)
+		}
+		n, err := strconv.ParseInt(lit, 10, 64)
+		if err != nil {
+			return nil, &ParseError{Message: fmt.Sprintf("integer literal %s is out of range", lit), Pos: pos}
+		}
+
+		ps := &PathSelector{Kind: ShortestK, Count: n}
+		p.scanPathsWord()
+		if tok, _, lit := p.ScanIgnoreWhitespace(); isContextualKeyword(tok, lit, "GROUPS") {
+			ps.Kind = ShortestKGroups
+		} else {
+			p.Unscan()
+		}
+		return ps, nil
+	}
+
+	p.Unscan()
+	return nil, nil
+}
+
+// scanPathsWord consumes an optional PATH or PATHS.
+func (p *Parser) scanPathsWord() {
+	tok, _, lit := p.ScanIgnoreWhitespace()
+	if !isContextualKeyword(tok, lit, "PATH") && !isContextualKeyword(tok, lit, "PATHS") {
+		p.Unscan()
+	}
+}
+
+// ScanPatternElements ...
+func (p *Parser) ScanPatternElements() (pe []PatternElement, err error) {
+	for {
+		if p.subPathNext() {
+			p.ScanIgnoreWhitespace()
+			qp, err := p.scanQuantifiedPattern()
+			if err != nil {
+				return nil, err
+			}
+			pe = append(pe, qp)
+			continue
+		}
+
+		// a node starts the pattern, and may follow a sub-path directly
+		if len(pe) == 0 || isQuantifiedPattern(pe[len(pe)-1]) {
+			node, err := p.ScanNodePattern()
+			if err != nil {
+				return nil, err
+			} else if node != nil {
+				pe = append(pe, node)
+				continue
+			} else if len(pe) == 0 {
+				tok, pos, lit := p.ScanIgnoreWhitespace()
+				return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+			}
+		}
+
+		edge, err := p.ScanEdgePattern()
+		if err != nil {
+			return nil, err
+		} else if edge == nil {
+			break
+		}
+		pe = append(pe, edge)
+
+		node, err := p.ScanNodePattern()
+		if err != nil {
+			return nil, err
+		} else if node == nil {
+			// an edge must always be followed by a node
+			tok, pos, lit := p.ScanIgnoreWhitespace()
+			return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+		}
+		pe = append(pe, node)
+	}
+
+	// parens around the whole pattern are only nesting
+	if len(pe) == 1 {
+		if qp, ok := pe[0].(*QuantifiedPattern); ok && qp.Where == nil && qp.Quantifier == nil {
+			return qp.Elements, nil
+		}
+	}
+	return pe, nil
+}
+
+func isQuantifiedPattern(e PatternElement) bool {
+	_, ok := e.(*QuantifiedPattern)
+	return ok
+}
+
+// subPathNext reports whether a parenthesised sub-path, e.g.
+// `((a)-->(b))`, comes next. Nothing is consumed.
+func (p *Parser) subPathNext() bool {
+	m := p.s.mark()
+	defer p.s.reset(m)
+
+	// a node pattern can't hold a paren
+	tok, _, _ := p.ScanIgnoreWhitespace()
+	tok1, _, _ := p.ScanIgnoreWhitespace()
+	return tok == LPAREN && tok1 == LPAREN
+}
+
+// scanQuantifiedPattern parses a parenthesised sub-path with its optional
+// WHERE and quantifier, after the `(`.
+func (p *Parser) scanQuantifiedPattern() (*QuantifiedPattern, error) {
+	elems, err := p.ScanPatternElements()
+	if err != nil {
+		return nil, err
+	}
+	qp := &QuantifiedPattern{Elements: elems}
+
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok == WHERE {
+		if qp.Where, err = p.ScanExpression(); err != nil {
+			return nil, err
+		}
+		tok, pos, lit = p.ScanIgnoreWhitespace()
+	}
+	if tok != RPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+	}
+
+	if qp.Quantifier, err = p.scanPathQuantifier(); err != nil {
+		return nil, err
+	}
+	return qp, nil
+}
+
+// scanPathQuantifier consumes the quantifier of a sub-path, i.e. `+`, `*` or
+// `{m,n}` where either bound can be omitted, if any.
+func (p *Parser) scanPathQuantifier() (*PathQuantifier, error) {
+	zero, one := 0, 1
+	switch tok, _, _ := p.ScanIgnoreWhitespace(); tok {
+	case PLUS:
+		return &PathQuantifier{Min: &one}, nil
+	case MUL:
+		return &PathQuantifier{Min: &zero}, nil
+	case LBRACE:
+	default:
+		p.Unscan()
+		return nil, nil
+	}
+
+	min, err := p.scanHops()
+	if err != nil {
+		return nil, err
+	}
+	// a single bound means an exact number of repetitions
+	pq := &PathQuantifier{Min: min, Max: min}
+
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok == COMMA {
+		if pq.Max, err = p.scanHops(); err != nil {
+			return nil, err
+		}
+		tok, pos, lit = p.ScanIgnoreWhitespace()
+	} else if min == nil {
+		return nil, newParseError(tokstr(tok, lit), []string{"Integer", ","}, pos)
+	}
+	if tok != RBRACE {
+		return nil, newParseError(tokstr(tok, lit), []string{"}"}, pos)
+	}
+	return pq, nil
+}
+
+// ScanNodePattern returns a NodePattern if possible to consume a complete valid node.
+// Nothing is consumed otherwise.
+func (p *Parser) ScanNodePattern() (*NodePattern, error) {
+	m := p.s.mark()
+	node, err := p.scanNodePattern()
+	if node == nil && err == nil {
+		p.s.reset(m)
+	} else {
+		p.s.release()
+	}
+	return node, err
+}
+
+func (p *Parser) scanNodePattern() (*NodePattern, error) {
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != LPAREN {
+		// We already know we cannot consume a valid node if the pattern doesn't start with `(`
+		return nil, nil
+	}
+
+	var validNode bool
+	var node NodePattern
+	if tok, _, lit := p.ScanIgnoreWhitespace(); tok == IDENT {
+		v := Variable(lit)
+		node.Variable = &v
+		validNode = true
+	} else {
+		p.Unscan()
+	}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == COLON {
+		labels, err := p.scanLabelExpr("Label Identifier", false)
+		if err != nil {
+			return nil, err
+		}
+		node.Labels = labels
+		validNode = true
+	} else {
+		p.Unscan()
+	}
+
+	props, param, err := p.scanPatternProperties()
+	if err != nil {
+		return nil, err
+	} else if props != nil || param != nil {
+		node.Properties = props
+		node.PropertiesParam = param
+		validNode = true
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok == RPAREN {
+		return &node, nil
+	} else if validNode && tok != RPAREN {
+		// We need to close the node definition
+		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+	}
+
+	return nil, nil
+}
+
+// scanLabelExpr parses a label expression, e.g. `Person&!(Banned|Deleted)`,
+// after the `:`. The `|` binds weaker than `&`, which binds weaker than `!`.
+// Consecutive `:Label` names are a conjunction as well. In expressions a `|`
+// might separate the projection of a comprehension instead, so alternatives
+// are only taken there if they can't be read as the projection.
+func (p *Parser) scanLabelExpr(expected string, inExpr bool) (LabelExpr, error) {
+	expr, err := p.scanLabelOr(expected, inExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != COLON {
+			p.Unscan()
+			return expr, nil
+		}
+		rhs, err := p.scanLabelOr(expected, inExpr)
+		if err != nil {
+			return nil, err
+		}
+		expr = LabelAnd{LHS: expr, RHS: rhs}
+	}
+}
+
+func (p *Parser) scanLabelOr(expected string, inExpr bool) (LabelExpr, error) {
+	expr, err := p.scanLabelAnd(expected)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		m := p.s.mark()
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != BAR {
+			p.s.reset(m)
+			return expr, nil
+		}
+		// the colon is optional for the following alternatives
+		tok, _, _ := p.ScanIgnoreWhitespace()
+		if tok != COLON {
+			p.Unscan()
+		}
+		rhs, err := p.scanLabelAnd(expected)
+		if inExpr && tok != COLON && (err != nil || !p.atLabelExprEnd()) {
+			// the bar starts a projection, e.g. `[x IN l WHERE x:A | x.name]`
+			p.s.reset(m)
+			return expr, nil
+		}
+		p.s.release()
+		if err != nil {
+			return nil, err
+		}
+		expr = LabelOr{LHS: expr, RHS: rhs}
+	}
+}
+
+// atLabelExprEnd reports whether the next token may follow a label
+// expression in an expression, without consuming it. A closing `]` isn't
+// one of them, the alternative is the projection of a comprehension then.
+func (p *Parser) atLabelExprEnd() bool {
+	tok, _, _ := p.ScanIgnoreWhitespace()
+	p.Unscan()
+	switch tok {
+	case BAR, COLON, RPAREN, COMMA, RBRACE, SEMICOLON, EOF, AND, OR, XOR:
+		return true
+	}
+	// clause keywords, but not predicates such as IN
+	return tok.isKeyword() && tok.Precedence() == 0
+}
+
+func (p *Parser) scanLabelAnd(expected string) (LabelExpr, error) {
+	expr, err := p.scanLabelAtom(expected)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != AMP {
+			p.Unscan()
+			return expr, nil
+		}
+		rhs, err := p.scanLabelAtom(expected)
+		if err != nil {
+			return nil, err
+		}
+		expr = LabelAnd{LHS: expr, RHS: rhs}
+	}
+}
+
+func (p *Parser) scanLabelAtom(expected string) (LabelExpr, error) {
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	switch tok {
+	case IDENT:
+		return LabelName(lit), nil
+	case MOD:
+		return LabelWildcard{}, nil
+	case BANG:
+		expr, err := p.scanLabelAtom(expected)
+		if err != nil {
+			return nil, err
+		}
+		return LabelNot{Expr: expr}, nil
+	case LPAREN:
+		expr, err := p.scanLabelExpr(expected, false)
+		if err != nil {
+			return nil, err
+		}
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
+			return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+		}
+		return LabelGroup{Expr: expr}, nil
+	}
+	return nil, newParseError(tokstr(tok, lit), []string{expected}, pos)
+}
+
+// ScanEdgePattern returns an EdgePattern if possible to consume a complete valid edge.
+func (p *Parser) ScanEdgePattern() (*EdgePattern, error) {
+	var left, right bool
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == LT {
+		left = true
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != SUB {
+			return nil, newParseError(tokstr(tok, lit), []string{"-"}, pos)
+		}
+	} else if tok != SUB {
+		// We already know this is not an edge if it doesn't start with `<-` or `-`
+		p.Unscan()
+		return nil, nil
+	}
+
+	edge := &EdgePattern{}
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == LBRACKET {
+		if err := p.scanEdgeDetail(edge); err != nil {
+			return nil, err
+		}
+	} else {
+		p.Unscan()
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != SUB {
+		return nil, newParseError(tokstr(tok, lit), []string{"-"}, pos)
+	}
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == GT {
+		right = true
+	} else {
+		p.Unscan()
+	}
+
+	switch {
+	case left && right:
+		edge.Direction = EdgeOutgoing
+	case left:
+		edge.Direction = EdgeLeft
+	case right:
+		edge.Direction = EdgeRight
+	default:
+		edge.Direction = EdgeUndefined
+	}
+
+	return edge, nil
+}
+
+// scanEdgeDetail consumes the bracketed part of an edge, after the opening `[`.
+func (p *Parser) scanEdgeDetail(edge *EdgePattern) error {
+	if tok, _, lit := p.ScanIgnoreWhitespace(); tok == IDENT {
+		edge.Variable = &lit
+	} else {
+		p.Unscan()
+	}
+
+	// relationship types, e.g. alternatives separated by `|`
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == COLON {
+		labels, err := p.scanLabelExpr("Type Identifier", false)
+		if err != nil {
+			return err
+		}
+		edge.Labels = labels
+	} else {
+		p.Unscan()
+	}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == MUL {
+		if err := p.scanEdgeRange(edge); err != nil {
+			return err
+		}
+	} else {
+		p.Unscan()
+	}
+
+	props, param, err := p.scanPatternProperties()
+	if err != nil {
+		return err
+	}
+	edge.Properties = props
+	edge.PropertiesParam = param
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RBRACKET {
+		return newParseError(tokstr(tok, lit), []string{"]"}, pos)
+	}
+	return nil
+}
+
+// scanEdgeRange consumes a variable length range, after the `*`.
+func (p *Parser) scanEdgeRange(edge *EdgePattern) error {
+	edge.VarLength = true
+
+	min, err := p.scanHops()
+	if err != nil {
+		return err
+	}
+	edge.MinHops = min
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != DOUBLEDOT {
+		// a single bound means an exact number of hops
+		p.Unscan()
+		edge.MaxHops = min
+		return nil
+	}
+
+	max, err := p.scanHops()
+	if err != nil {
+		return err
+	}
+	edge.MaxHops = max
+	return nil
+}
+
+// scanHops consumes an optional integer bound of an edge range.
+func (p *Parser) scanHops() (*int, error) {
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok != INTEGER {
+		p.Unscan()
+		return nil, nil
+	}
+	n, err := strconv.Atoi(lit)
+	if err != nil {
+		return nil, &ParseError{Message: fmt.Sprintf("invalid range bound %s", lit), Pos: pos}
+	}
+	return &n, nil
+}
+
+// ScanExpression parses an expression, respecting the operators precedence.
+func (p *Parser) ScanExpression() (Expr, error) {
+	return p.scanBinaryExpr(1)
+}
+
+// scanBinaryExpr parses a chain of binary operators binding at least as
+// strong as minPrec. All binary operators are left associative.
+func (p *Parser) scanBinaryExpr(minPrec int) (Expr, error) {
+	lhs, err := p.scanUnaryExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, _, _ := p.ScanIgnoreWhitespace()
+		prec := op.Precedence()
+		if prec == 0 || prec < minPrec {
+			p.Unscan()
+			return lhs, nil
+		}
+
+		if prec == predicatePrecedence {
+			if lhs, err = p.scanPredicate(op, lhs); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		rhs, err := p.scanBinaryExpr(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		lhs = BinaryExpr{Op: op, LHS: lhs, RHS: rhs}
+	}
+}
+
+// scanPredicate parses the rest of a predicate on lhs, after its first token.
+func (p *Parser) scanPredicate(op Token, lhs Expr) (Expr, error) {
+	switch op {
+	case IS:
+		nc := NullCheck{Expr: lhs}
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		if tok == NOT {
+			nc.Not = true
+			tok, pos, lit = p.ScanIgnoreWhitespace()
+		}
+		if tok != NULL {
+			expected := []string{"NULL"}
+			if !nc.Not {
+				expected = []string{"NOT", "NULL"}
+			}
+			return nil, newParseError(tokstr(tok, lit), expected, pos)
+		}
+		return nc, nil
+	case STARTS, ENDS:
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != WITH {
+			return nil, newParseError(tokstr(tok, lit), []string{"WITH"}, pos)
+		}
+	}
+
+	rhs, err := p.scanBinaryExpr(predicatePrecedence + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case IN:
+		return InExpr{Expr: lhs, List: rhs}, nil
+	case REGEX:
+		return RegexMatch{Expr: lhs, Pattern: rhs}, nil
+	}
+	return StringPredicate{Op: op, LHS: lhs, RHS: rhs}, nil
+}
+
+// scanUnaryExpr parses an expression optionally prefixed by NOT, `-` or `+`.
+func (p *Parser) scanUnaryExpr() (Expr, error) {
+	tok, _, _ := p.ScanIgnoreWhitespace()
+	switch tok {
+	case NOT:
+		expr, err := p.scanBinaryExpr(notPrecedence + 1)
+		if err != nil {
+			return nil, err
+		}
+		return UnaryExpr{Op: NOT, Expr: expr}, nil
+	case SUB, PLUS:
+		// negative numbers are folded into the literal, so the full int64
+		// range can be represented
+		if tok == SUB {
+			if tok1, pos, lit := p.ScanIgnoreWhitespace(); tok1 == INTEGER || tok1 == NUMBER {
+				return newNumberLiteral(tok1, "-"+lit, pos)
+			}
+			p.Unscan()
+		}
+		expr, err := p.scanUnaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		return UnaryExpr{Op: tok, Expr: expr}, nil
+	}
+	p.Unscan()
+	return p.scanPostfixExpr()
+}
+
+// scanPostfixExpr parses an expression followed by any chain of property
+// lookups, e.g. `n.address.city`, indexes, e.g. `list[0]`, and slices, e.g.
+// `list[1..3]`.
+func (p *Parser) scanPostfixExpr() (Expr, error) {
+	expr, err := p.scanPrimaryExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch tok, _, _ := p.ScanIgnoreWhitespace(); tok {
+		case DOT:
+			key, err := p.scanNameAfterDot("Property Key")
+			if err != nil {
+				return nil, err
+			}
+			expr = PropertyAccess{Expr: expr, Key: key}
+		case LBRACKET:
+			if expr, err = p.scanIndexOrSlice(expr); err != nil {
+				return nil, err
+			}
+		case COLON:
+			labels, err := p.scanLabelExpr("Label Identifier", true)
+			if err != nil {
+				return nil, err
+			}
+			expr = LabelPredicate{Expr: expr, Labels: labels}
+		default:
+			p.Unscan()
+			return expr, nil
+		}
+	}
+}
+
+// scanIndexOrSlice parses an index or a slice of expr, after the `[`.
+func (p *Parser) scanIndexOrSlice(expr Expr) (Expr, error) {
+	var from, to Expr
+	var err error
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != DOUBLEDOT {
+		p.Unscan()
+		if from, err = p.ScanExpression(); err != nil {
+			return nil, err
+		}
+
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		if tok == RBRACKET {
+			return IndexExpr{Expr: expr, Index: from}, nil
+		} else if tok != DOUBLEDOT {
+			return nil, newParseError(tokstr(tok, lit), []string{"]", ".."}, pos)
+		}
+	}
+
+	// both bounds of a slice are optional
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != RBRACKET {
+		p.Unscan()
+		if to, err = p.ScanExpression(); err != nil {
+			return nil, err
+		}
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RBRACKET {
+			return nil, newParseError(tokstr(tok, lit), []string{"]"}, pos)
+		}
+	}
+	return SliceExpr{Expr: expr, From: from, To: to}, nil
+}
+
+// scanPrimaryExpr parses an expression without operators.
+func (p *Parser) scanPrimaryExpr() (Expr, error) {
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	switch tok {
+	case IDENT:
+		p.Unscan()
+		return p.scanNameExpr()
+	case STRING:
+		return StrLiteral(lit), nil
+	case INTEGER, NUMBER:
+		return newNumberLiteral(tok, lit, pos)
+	case TRUE:
+		return BoolLiteral(true), nil
+	case FALSE:
+		return BoolLiteral(false), nil
+	case NULL:
+		return NullLiteral{}, nil
+	case LBRACKET:
+		return p.scanListExpr()
+	case EXISTS:
+		// `exists(n.prop)` is a function, `EXISTS { ... }` a subquery
+		switch tok, pos, lit := p.ScanIgnoreWhitespace(); tok {
+		case LPAREN:
+			return p.scanFunctionCall(nil, "exists")
+		case LBRACE:
+			return p.scanSubqueryExpr(SubqueryExists)
+		default:
+			return nil, newParseError(tokstr(tok, lit), []string{"(", "{"}, pos)
+		}
+	case ALL:
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != LPAREN {
+			return nil, newParseError(tokstr(tok, lit), []string{"("}, pos)
+		}
+		return p.scanQuantifierExpr(QuantifierAll)
+	case LBRACE:
+		items, err := p.scanMapItems()
+		if err != nil {
+			return nil, err
+		}
+		return MapLiteral(items), nil
+	case PARAM:
+		return Parameter(lit), nil
+	case CASE:
+		return p.scanCaseExpr()
+	case LPAREN:
+		// a relationship pattern starts with a parenthesis as well
+		p.Unscan()
+		if pp := p.scanPatternPredicate(); pp != nil {
+			return *pp, nil
+		}
+		p.ScanIgnoreWhitespace()
+
+		expr, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
+			return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+		}
+		return expr, nil
+	}
+	return nil, newParseError(tokstr(tok, lit), []string{"expression"}, pos)
+}
 
-	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok == RPAREN {
-		return &node, nil
-	} else if validNode && tok != RPAREN {
-		// We need to close the node definition
-		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+// scanNameExpr parses an expression starting with a name, which is either a
+// function call, e.g. `apoc.text.join(list, ',')`, or a variable optionally
+// followed by property lookups.
+func (p *Parser) scanNameExpr() (Expr, error) {
+	names, err := p.scanNamespacedName()
+	if err != nil {
+		return nil, err
 	}
 
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == LPAREN {
+		if len(names) == 1 {
+			switch strings.ToLower(names[0]) {
+			case "any":
+				return p.scanQuantifierExpr(QuantifierAny)
+			case "none":
+				return p.scanQuantifierExpr(QuantifierNone)
+			case "single":
+				return p.scanQuantifierExpr(QuantifierSingle)
+			case "reduce":
+				return p.scanReduceExpr()
+			}
+		}
+		return p.scanFunctionCall(names[:len(names)-1], names[len(names)-1])
+	}
 	p.Unscan()
-	p.Unscan() // unscan the first LPAREN then
-	return nil, nil
+
+	if len(names) == 1 {
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == LBRACE {
+			switch strings.ToLower(names[0]) {
+			case "count":
+				return p.scanSubqueryExpr(SubqueryCount)
+			case "collect":
+				return p.scanSubqueryExpr(SubqueryCollect)
+			}
+			return p.scanMapProjection(Variable(names[0]))
+		}
+		p.Unscan()
+	}
+
+	var expr Expr = Variable(names[0])
+	for _, key := range names[1:] {
+		expr = PropertyAccess{Expr: expr, Key: key}
+	}
+	return expr, nil
 }
 
-// ScanEdgePattern returns an EdgePattern if possible to consume a complete valid edge.
-func (p *Parser) ScanEdgePattern() (*EdgePattern, error) {
-	return nil, nil
+// scanListExpr parses a list literal, a list comprehension or a pattern
+// comprehension, after the `[`.
+func (p *Parser) scanListExpr() (Expr, error) {
+	if v, ok := p.scanFilterVariable(); ok {
+		return p.scanListComprehension(v)
+	}
+
+	if pc, err := p.scanPatternComprehension(); err != nil {
+		return nil, err
+	} else if pc != nil {
+		return *pc, nil
+	}
+
+	items, err := p.scanListItems()
+	if err != nil {
+		return nil, err
+	}
+	return ListLiteral(items), nil
 }
 
-// ScanExpression ...
-func (p *Parser) ScanExpression() (Expr, error) {
-	return Variable("p_test"), nil
+// scanFilterVariable consumes `variable IN` if it comes next, and reports
+// whether it did.
+func (p *Parser) scanFilterVariable() (Variable, bool) {
+	m := p.s.mark()
+	if tok, _, lit := p.ScanIgnoreWhitespace(); tok == IDENT {
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == IN {
+			p.s.release()
+			return Variable(lit), true
+		}
+	}
+	p.s.reset(m)
+	return "", false
+}
+
+// scanFilter parses the list and the optional WHERE predicate of a filter,
+// e.g. `x IN list WHERE x > 0`, after the `IN`.
+func (p *Parser) scanFilter() (list Expr, where Expr, err error) {
+	if list, err = p.ScanExpression(); err != nil {
+		return nil, nil, err
+	}
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == WHERE {
+		if where, err = p.ScanExpression(); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		p.Unscan()
+	}
+	return list, where, nil
+}
+
+// scanListComprehension parses a list comprehension, after the `IN`.
+func (p *Parser) scanListComprehension(v Variable) (Expr, error) {
+	list, where, err := p.scanFilter()
+	if err != nil {
+		return nil, err
+	}
+	lc := ListComprehension{Variable: v, List: list, Where: where}
+
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok == BAR {
+		if lc.Projection, err = p.ScanExpression(); err != nil {
+			return nil, err
+		}
+		tok, pos, lit = p.ScanIgnoreWhitespace()
+	}
+	if tok != RBRACKET {
+		return nil, newParseError(tokstr(tok, lit), []string{"]"}, pos)
+	}
+	return lc, nil
+}
+
+// scanPatternComprehension parses a pattern comprehension after the `[`, if
+// the list starts with a pattern followed by WHERE or `|`.
+func (p *Parser) scanPatternComprehension() (*PatternComprehension, error) {
+	m := p.s.mark()
+	if p.noComprehension[m] {
+		p.s.reset(m)
+		return nil, nil
+	}
+	mp, err := p.ScanMatchPattern()
+	if err != nil || len(mp.Elements) < 3 {
+		p.s.reset(m)
+		p.noComprehension[m] = true
+		return nil, nil
+	}
+	tok, _, _ := p.ScanIgnoreWhitespace()
+	if tok != WHERE && tok != BAR {
+		p.s.reset(m)
+		p.noComprehension[m] = true
+		return nil, nil
+	}
+	p.s.release()
+
+	pc := &PatternComprehension{Pattern: *mp}
+	if tok == WHERE {
+		if pc.Where, err = p.ScanExpression(); err != nil {
+			return nil, err
+		}
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != BAR {
+			return nil, newParseError(tokstr(tok, lit), []string{"|"}, pos)
+		}
+	}
+	if pc.Projection, err = p.ScanExpression(); err != nil {
+		return nil, err
+	}
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RBRACKET {
+		return nil, newParseError(tokstr(tok, lit), []string{"]"}, pos)
+	}
+	return pc, nil
+}
+
+// scanPatternPredicate consumes a relationship pattern, e.g. `(a)-->(b)`,
+// if it comes next. Otherwise nothing is consumed.
+func (p *Parser) scanPatternPredicate() *PatternPredicate {
+	m := p.s.mark()
+	if p.noPredicate[m] {
+		p.s.reset(m)
+		return nil
+	}
+	elems, err := p.ScanPatternElements()
+	if err != nil || len(elems) < 3 {
+		// a single node is a parenthesised expression
+		p.s.reset(m)
+		p.noPredicate[m] = true
+		return nil
+	}
+	p.s.release()
+	return &PatternPredicate{Pattern: MatchPattern{Elements: elems}}
+}
+
+// scanQuantifierExpr parses the filter of a quantifier, e.g. `all(x IN list
+// WHERE x > 0)`, after the `(`.
+func (p *Parser) scanQuantifierExpr(q Quantifier) (Expr, error) {
+	v, ok := p.scanFilterVariable()
+	if !ok {
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		return nil, newParseError(tokstr(tok, lit), []string{"Variable IN"}, pos)
+	}
+	list, where, err := p.scanFilter()
+	if err != nil {
+		return nil, err
+	}
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+	}
+	return QuantifierExpr{Quantifier: q, Variable: v, List: list, Where: where}, nil
+}
+
+// scanReduceExpr parses `reduce(acc = init, x IN list | expr)`, after the `(`.
+func (p *Parser) scanReduceExpr() (Expr, error) {
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok != IDENT {
+		return nil, newParseError(tokstr(tok, lit), []string{"Variable"}, pos)
+	}
+	re := ReduceExpr{Accumulator: Variable(lit)}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != EQ {
+		return nil, newParseError(tokstr(tok, lit), []string{"="}, pos)
+	}
+	var err error
+	if re.Init, err = p.ScanExpression(); err != nil {
+		return nil, err
+	}
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != COMMA {
+		return nil, newParseError(tokstr(tok, lit), []string{","}, pos)
+	}
+
+	v, ok := p.scanFilterVariable()
+	if !ok {
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		return nil, newParseError(tokstr(tok, lit), []string{"Variable IN"}, pos)
+	}
+	re.Variable = v
+	if re.List, err = p.ScanExpression(); err != nil {
+		return nil, err
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != BAR {
+		return nil, newParseError(tokstr(tok, lit), []string{"|"}, pos)
+	}
+	if re.Expr, err = p.ScanExpression(); err != nil {
+		return nil, err
+	}
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{")"}, pos)
+	}
+	return re, nil
+}
+
+// scanFunctionCall parses the arguments of a function call, after the `(`.
+func (p *Parser) scanFunctionCall(namespace []string, name string) (Expr, error) {
+	fc := FunctionCall{Namespace: namespace, Name: name}
+
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == DISTINCT {
+		fc.Distinct = true
+	} else {
+		p.Unscan()
+	}
+
+	switch tok, _, _ := p.ScanIgnoreWhitespace(); tok {
+	case RPAREN:
+		return fc, nil
+	case MUL:
+		fc.Star = true
+	default:
+		p.Unscan()
+		args, err := p.scanExpressions()
+		if err != nil {
+			return nil, err
+		}
+		fc.Args = args
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RPAREN {
+		return nil, newParseError(tokstr(tok, lit), []string{",", ")"}, pos)
+	}
+	return fc, nil
+}
+
+// scanCaseExpr parses a simple or searched CASE expression, after the CASE keyword.
+func (p *Parser) scanCaseExpr() (Expr, error) {
+	expr := CaseExpr{}
+
+	// the simple form compares an input to each WHEN value
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != WHEN {
+		p.Unscan()
+		input, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		expr.Input = input
+	} else {
+		p.Unscan()
+	}
+
+	for {
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		if tok != WHEN {
+			if len(expr.Whens) == 0 {
+				return nil, newParseError(tokstr(tok, lit), []string{"WHEN"}, pos)
+			}
+			p.Unscan()
+			break
+		}
+
+		when, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != THEN {
+			return nil, newParseError(tokstr(tok, lit), []string{"THEN"}, pos)
+		}
+		then, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		expr.Whens = append(expr.Whens, CaseWhen{When: when, Then: then})
+	}
+
+	expected := []string{"WHEN", "ELSE", "END"}
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == ELSE {
+		e, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		expr.Else = e
+		expected = []string{"END"}
+	} else {
+		p.Unscan()
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != END {
+		return nil, newParseError(tokstr(tok, lit), expected, pos)
+	}
+	return expr, nil
+}
+
+// newNumberLiteral returns the literal for an INTEGER or NUMBER token.
+func newNumberLiteral(tok Token, lit string, pos Pos) (Expr, error) {
+	if tok == INTEGER {
+		n, err := strconv.ParseInt(lit, 10, 64)
+		if err != nil {
+			return nil, &ParseError{Message: fmt.Sprintf("integer literal %s is out of range", lit), Pos: pos}
+		}
+		return IntegerLiteral(n), nil
+	}
+	f, err := strconv.ParseFloat(lit, 64)
+	if err != nil {
+		return nil, &ParseError{Message: fmt.Sprintf("float literal %s is out of range", lit), Pos: pos}
+	}
+	return FloatLiteral{Value: f, Text: lit}, nil
 }
 
-// ScanProperties ...
+// ScanProperties returns the properties map if possible to consume a `{key: value, ...}` map.
 func (p *Parser) ScanProperties() (*map[string]Expr, error) {
-	return nil, nil
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != LBRACE {
+		p.Unscan()
+		return nil, nil
+	}
+
+	items, err := p.scanMapItems()
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]Expr, len(items))
+	for _, item := range items {
+		props[item.Key] = item.Value
+	}
+	return &props, nil
+}
+
+// scanMapItems consumes the `key: value` pairs of a map, after the opening `{`.
+func (p *Parser) scanMapItems() ([]MapItem, error) {
+	var items []MapItem
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == RBRACE {
+		return items, nil
+	}
+	p.Unscan()
+
+	keys := make(map[string]bool)
+	for {
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		if tok == RBRACE {
+			return nil, &ParseError{Message: "unexpected trailing comma in map", Pos: pos}
+		} else if tok != IDENT && !tok.isKeyword() {
+			// keywords are valid property keys, e.g. `{end: 1}`
+			return nil, newParseError(tokstr(tok, lit), []string{"Property Key"}, pos)
+		} else if keys[lit] {
+			return nil, &ParseError{Message: fmt.Sprintf("duplicate property key %s", lit), Pos: pos}
+		}
+		keys[lit] = true
+		key := lit
+
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != COLON {
+			return nil, newParseError(tokstr(tok, lit), []string{":"}, pos)
+		}
+
+		value, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, MapItem{Key: key, Value: value})
+
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok == RBRACE {
+			return items, nil
+		} else if tok != COMMA {
+			return nil, newParseError(tokstr(tok, lit), []string{",", "}"}, pos)
+		}
+	}
+}
+
+// scanSubqueryExpr parses the body of an EXISTS, COUNT or COLLECT subquery,
+// after the `{`. EXISTS and COUNT also accept a pattern with an optional
+// WHERE instead of a full query.
+func (p *Parser) scanSubqueryExpr(kind SubqueryKind) (Expr, error) {
+	se := SubqueryExpr{Kind: kind}
+
+	var err error
+	tok, _, _ := p.ScanIgnoreWhitespace()
+	p.Unscan()
+	if kind != SubqueryCollect && (tok == LPAREN || tok == IDENT) {
+		if se.Pattern, err = p.scanMatchPatterns(true); err != nil {
+			return nil, err
+		}
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == WHERE {
+			if se.Where, err = p.ScanExpression(); err != nil {
+				return nil, err
+			}
+		} else {
+			p.Unscan()
+		}
+	} else {
+		q, err := p.parseUnionQuery(kind != SubqueryCollect)
+		if err != nil {
+			return nil, err
+		}
+		se.Query = &q
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != RBRACE {
+		return nil, newParseError(tokstr(tok, lit), []string{"}"}, pos)
+	}
+	return se, nil
+}
+
+// scanMapProjection consumes the entries of a map projection, after the
+// opening `{`.
+func (p *Parser) scanMapProjection(v Variable) (Expr, error) {
+	mp := MapProjection{Variable: v}
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == RBRACE {
+		return mp, nil
+	}
+	p.Unscan()
+
+	for {
+		var item MapProjectionItem
+		switch tok, pos, lit := p.ScanIgnoreWhitespace(); tok {
+		case DOT:
+			if tok, _, _ := p.ScanIgnoreWhitespace(); tok == MUL {
+				item.AllProperties = true
+				break
+			}
+			p.Unscan()
+			key, err := p.scanNameAfterDot("Property Key")
+			if err != nil {
+				return nil, err
+			}
+			item.Key = key
+		case RBRACE:
+			return nil, &ParseError{Message: "unexpected trailing comma in map", Pos: pos}
+		default:
+			if tok != IDENT && !tok.isKeyword() {
+				return nil, newParseError(tokstr(tok, lit), []string{".", "Property Key"}, pos)
+			}
+			// a name on its own selects a variable, keywords are only keys
+			if tok1, pos1, lit1 := p.ScanIgnoreWhitespace(); tok1 != COLON {
+				if tok != IDENT {
+					return nil, newParseError(tokstr(tok1, lit1), []string{":"}, pos1)
+				}
+				p.Unscan()
+				v := Variable(lit)
+				item.Variable = &v
+				break
+			}
+			value, err := p.ScanExpression()
+			if err != nil {
+				return nil, err
+			}
+			item.Key, item.Value = lit, value
+		}
+		mp.Items = append(mp.Items, item)
+
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok == RBRACE {
+			return mp, nil
+		} else if tok != COMMA {
+			return nil, newParseError(tokstr(tok, lit), []string{",", "}"}, pos)
+		}
+	}
+}
+
+// scanListItems consumes the comma separated items of a list, after the opening `[`.
+func (p *Parser) scanListItems() ([]Expr, error) {
+	var items []Expr
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == RBRACKET {
+		return items, nil
+	}
+	p.Unscan()
+
+	for {
+		item, err := p.ScanExpression()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok == RBRACKET {
+			return items, nil
+		} else if tok != COMMA {
+			return nil, newParseError(tokstr(tok, lit), []string{",", "]"}, pos)
+		}
+	}
+}
+
+// scanPatternProperties consumes the properties of a node or edge, given
+// either as a map or as a parameter.
+func (p *Parser) scanPatternProperties() (map[string]Expr, *Parameter, error) {
+	if tok, _, lit := p.ScanIgnoreWhitespace(); tok == PARAM {
+		param := Parameter(lit)
+		return nil, &param, nil
+	}
+	p.Unscan()
+
+	props, err := p.ScanProperties()
+	if err != nil || props == nil {
+		return nil, nil, err
+	}
+	return *props, nil, nil
 }
 
 // Scan returns the next token from the underlying scanner.
-func (p *Parser) Scan() (tok Token, pos Pos, lit string) { return p.s.Scan() }
+func (p *Parser) Scan() (tok Token, pos Pos, lit string) {
+	tok, pos, lit = p.s.Scan()
+	if tok == PARAM {
+		p.addParam(lit, pos)
+	}
+	return
+}
+
+// addParam records a reference to a parameter. Tokens that are unscanned and
+// scanned again are only recorded once.
+func (p *Parser) addParam(name string, pos Pos) {
+	for i := range p.params {
+		ref := &p.params[i]
+		if ref.Name != name {
+			continue
+		}
+		for _, pos0 := range ref.Positions {
+			if pos0 == pos {
+				return
+			}
+		}
+		ref.Positions = append(ref.Positions, pos)
+		return
+	}
+	p.params = append(p.params, ParameterRef{Name: name, Positions: []Pos{pos}})
+}
 
 // ScanIgnoreWhitespace scans the next non-whitespace and non-comment token.
 func (p *Parser) ScanIgnoreWhitespace() (tok Token, pos Pos, lit string) {
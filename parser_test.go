@@ -1,8 +1,11 @@
 package cypher_test
 
 import (
+	"io"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rafaelcaricio/cypher-parser"
 )
@@ -13,36 +16,36 @@ func TestParseNodePatterns(t *testing.T) {
 		out string
 	}{
 		{
-			in:  "MATCH (p) RETURN",
-			out: "MATCH (p) RETURN",
+			in:  "MATCH (p) RETURN *",
+			out: "MATCH (p) RETURN *",
 		},
 		{
-			in:  "MATCH ((((())))) RETURN",
-			out: "MATCH () RETURN",
+			in:  "MATCH ((((())))) RETURN *",
+			out: "MATCH () RETURN *",
 		},
 		{
-			in:  "MATCH () RETURN",
-			out: "MATCH () RETURN",
+			in:  "MATCH () RETURN *",
+			out: "MATCH () RETURN *",
 		},
 		{
-			in:  "MATCH (p :Person) RETURN",
-			out: "MATCH (p :Person) RETURN",
+			in:  "MATCH (p :Person) RETURN *",
+			out: "MATCH (p :Person) RETURN *",
 		},
 		{
-			in:  "MATCH ((p :Person)) RETURN",
-			out: "MATCH (p :Person) RETURN",
+			in:  "MATCH ((p :Person)) RETURN *",
+			out: "MATCH (p :Person) RETURN *",
 		},
 		{
-			in:  "MATCH (p :Person :Human) RETURN",
-			out: "MATCH (p :Person :Human) RETURN",
+			in:  "MATCH (p :Person :Human) RETURN *",
+			out: "MATCH (p :Person :Human) RETURN *",
 		},
 		{
-			in:  "MATCH ( :Human) RETURN",
-			out: "MATCH ( :Human) RETURN",
+			in:  "MATCH ( :Human) RETURN *",
+			out: "MATCH ( :Human) RETURN *",
 		},
 		{
-			in:  "MATCH ( :Human ) RETURN;",
-			out: "MATCH ( :Human) RETURN",
+			in:  "MATCH ( :Human ) RETURN *;",
+			out: "MATCH ( :Human) RETURN *",
 		},
 	} {
 		q, err := cypher.ParseQuery(query.in)
@@ -56,3 +59,1548 @@ func TestParseNodePatterns(t *testing.T) {
 		}
 	}
 }
+
+func TestParseEdgePatterns(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "MATCH (a)-->(b) RETURN *",
+			out: "MATCH (a)-->(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)<--(b) RETURN *",
+			out: "MATCH (a)<--(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)--(b) RETURN *",
+			out: "MATCH (a)--(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)<-->(b) RETURN *",
+			out: "MATCH (a)<-->(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)-[r:KNOWS]->(b) RETURN *",
+			out: "MATCH (a)-[r:KNOWS]->(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a) <- [ r ] - (b) RETURN *",
+			out: "MATCH (a)<-[r]-(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)-[:KNOWS|:LIKES|FOLLOWS]-(b) RETURN *",
+			out: "MATCH (a)-[:KNOWS|LIKES|FOLLOWS]-(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)-[*]->(b) RETURN *",
+			out: "MATCH (a)-[*]->(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)-[r*2]->(b) RETURN *",
+			out: "MATCH (a)-[r*2]->(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)-[*..5]->(b) RETURN *",
+			out: "MATCH (a)-[*..5]->(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)-[:KNOWS*2..]->(b) RETURN *",
+			out: "MATCH (a)-[:KNOWS*2..]->(b) RETURN *",
+		},
+		{
+			in:  "MATCH (a)-[r:KNOWS * 2 .. 5]->(b)<--(c :Person) RETURN *",
+			out: "MATCH (a)-[r:KNOWS*2..5]->(b)<--(c :Person) RETURN *",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if strings.Trim(q.String(), " ") != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+}
+
+func TestParseEdgePatternErrors(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  "MATCH (a)-[r:KNOWS->(b) RETURN *",
+			err: "found -, expected ] at line 1, char 19",
+		},
+		{
+			in:  "MATCH (a)<-(b) RETURN *",
+			err: "found (, expected - at line 1, char 12",
+		},
+		{
+			in:  "MATCH (a)-->RETURN",
+			err: "found RETURN, expected ( at line 1, char 13",
+		},
+		{
+			in:  "MATCH (a)-[:]->(b) RETURN *",
+			err: "found ], expected Type Identifier at line 1, char 13",
+		},
+	} {
+		_, err := cypher.ParseQuery(query.in)
+		if err == nil {
+			t.Errorf("%s: expected error %q", query.in, query.err)
+		} else if err.Error() != query.err {
+			t.Errorf("%s:\nExpected:\n\t%s\nGot:\n\t%s", query.in, query.err, err)
+		}
+	}
+}
+
+func TestParseExpressions(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "a", out: "a"},
+		{in: "a OR b AND c", out: "a OR b AND c"},
+		{in: "(a OR b) AND c", out: "(a OR b) AND c"},
+		{in: "a XOR b OR c", out: "a XOR b OR c"},
+		{in: "a XOR (b OR c)", out: "a XOR (b OR c)"},
+		{in: "NOT a AND b", out: "NOT a AND b"},
+		{in: "NOT (a AND b)", out: "NOT (a AND b)"},
+		{in: "NOT a = b", out: "NOT a = b"},
+		{in: "(NOT a) = b", out: "(NOT a) = b"},
+		{in: "NOT NOT a", out: "NOT NOT a"},
+		{in: "a + b * c", out: "a + b * c"},
+		{in: "(a + b) * c", out: "(a + b) * c"},
+		{in: "a - b - c", out: "a - b - c"},
+		{in: "a - (b - c)", out: "a - (b - c)"},
+		{in: "((a)) / b % c", out: "a / b % c"},
+		{in: "a ^ b ^ c", out: "a ^ b ^ c"},
+		{in: "a ^ (b ^ c)", out: "a ^ (b ^ c)"},
+		{in: "-a ^ b", out: "-a ^ b"},
+		{in: "-(a ^ b)", out: "-(a ^ b)"},
+		{in: "- -a", out: "-(-a)"},
+		{in: "- -1", out: "-(-1)"},
+		{in: "-(-1)", out: "-(-1)"},
+		{in: "+ -1.5", out: "+(-1.5)"},
+		{in: "-+a", out: "-(+a)"},
+		{in: "a <> b OR a <= c AND a >= \"d\"", out: "a <> b OR a <= c AND a >= \"d\""},
+		{in: "a < b + c", out: "a < b + c"},
+		{in: "(a < b) + c", out: "(a < b) + c"},
+	} {
+		q, err := cypher.ParseQuery("MATCH (a) WHERE " + query.in + " RETURN *")
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		out := "MATCH (a) WHERE " + query.out + " RETURN *"
+		if strings.Trim(q.String(), " ") != out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", out, q)
+		}
+	}
+}
+
+func TestParseExpressionTree(t *testing.T) {
+	q, err := cypher.ParseQuery("MATCH (a) WHERE a OR b AND NOT c = d RETURN *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := cypher.BinaryExpr{
+		Op:  cypher.OR,
+		LHS: cypher.Variable("a"),
+		RHS: cypher.BinaryExpr{
+			Op:  cypher.AND,
+			LHS: cypher.Variable("b"),
+			RHS: cypher.UnaryExpr{
+				Op: cypher.NOT,
+				Expr: cypher.BinaryExpr{
+					Op:  cypher.EQ,
+					LHS: cypher.Variable("c"),
+					RHS: cypher.Variable("d"),
+				},
+			},
+		},
+	}
+	if w := q.Root.Reading[0].Where; w == nil || !reflect.DeepEqual(*w, cypher.Expr(exp)) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, w)
+	}
+}
+
+func TestParseProperties(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  `MATCH (n :User {name: "Adam"}) RETURN *`,
+			out: `MATCH (n :User {name: "Adam"}) RETURN *`,
+		},
+		{
+			in:  `MATCH (n {surname: "Smith", name: "Adam"}) RETURN *`,
+			out: `MATCH (n {name: "Adam", surname: "Smith"}) RETURN *`,
+		},
+		{
+			in:  "MATCH ({`first name`: \"Adam\"}) RETURN *",
+			out: "MATCH ( {`first name`: \"Adam\"}) RETURN *",
+		},
+		{
+			in:  `MATCH (n {}) RETURN *`,
+			out: `MATCH (n) RETURN *`,
+		},
+		{
+			in:  `MATCH (n {end: 1, Order: 2}) RETURN *`,
+			out: "MATCH (n {`Order`: 2, `end`: 1}) RETURN *",
+		},
+		{
+			in:  "MATCH (n {`Order`: 2, `end`: 1}) RETURN *",
+			out: "MATCH (n {`Order`: 2, `end`: 1}) RETURN *",
+		},
+		{
+			in:  `MATCH (n $props) RETURN *`,
+			out: `MATCH (n $props) RETURN *`,
+		},
+		{
+			in:  `MATCH (n {name: $name}) RETURN *`,
+			out: `MATCH (n {name: $name}) RETURN *`,
+		},
+		{
+			in:  `MATCH (a)-[r:KNOWS {since: "2010"}]->(b) RETURN *`,
+			out: `MATCH (a)-[r:KNOWS {since: "2010"}]->(b) RETURN *`,
+		},
+		{
+			in:  `MATCH (a)<-[{since: "2010"}]-(b) RETURN *`,
+			out: `MATCH (a)<-[{since: "2010"}]-(b) RETURN *`,
+		},
+		{
+			in:  `MATCH (a)-[r $props]-(b) RETURN *`,
+			out: `MATCH (a)-[r $props]-(b) RETURN *`,
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if strings.Trim(q.String(), " ") != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+}
+
+func TestParsePropertiesErrors(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  `MATCH (n {name: "Adam",}) RETURN *`,
+			err: "unexpected trailing comma in map at line 1, char 24",
+		},
+		{
+			in:  `MATCH (n {name: "Adam", name: "Eve"}) RETURN *`,
+			err: "duplicate property key name at line 1, char 25",
+		},
+		{
+			in:  `MATCH (n {name "Adam"}) RETURN *`,
+			err: "found Adam, expected : at line 1, char 15",
+		},
+		{
+			in:  `MATCH (n {name: "Adam" age: "1"}) RETURN *`,
+			err: "found age, expected ,, } at line 1, char 24",
+		},
+	} {
+		_, err := cypher.ParseQuery(query.in)
+		if err == nil {
+			t.Errorf("%s: expected error %q", query.in, query.err)
+		} else if err.Error() != query.err {
+			t.Errorf("%s:\nExpected:\n\t%s\nGot:\n\t%s", query.in, query.err, err)
+		}
+	}
+}
+
+func TestParseLiterals(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "1", out: "1"},
+		{in: "-42", out: "-42"},
+		{in: "- 42", out: "-42"},
+		{in: "9223372036854775807", out: "9223372036854775807"},
+		{in: "-9223372036854775808", out: "-9223372036854775808"},
+		{in: "3.14", out: "3.14"},
+		{in: "-0.5", out: "-0.5"},
+		{in: "1.0", out: "1.0"},
+		{in: "1e3", out: "1e3"},
+		{in: "1.5e-7", out: "1.5e-7"},
+		{in: "-2.50E+10", out: "-2.50E+10"},
+		{in: "true", out: "true"},
+		{in: "FALSE", out: "false"},
+		{in: "Null", out: "null"},
+		{in: `"say \"hi\"\n"`, out: `"say \"hi\"\n"`},
+		{in: `'single'`, out: `"single"`},
+		{in: "[]", out: "[]"},
+		{in: "[1, -2.5, 'a', null, [true, []]]", out: `[1, -2.5, "a", null, [true, []]]`},
+		{in: "{}", out: "{}"},
+		{in: "{b: 1, a: {c: [1, 2]}, `odd key`: $p}", out: "{b: 1, a: {c: [1, 2]}, `odd key`: $p}"},
+		{in: "{end: 1, order: 2, LIMIT: 3}", out: "{`end`: 1, `order`: 2, `LIMIT`: 3}"},
+		{in: "{`end`: 1, `order`: 2, `LIMIT`: 3}", out: "{`end`: 1, `order`: 2, `LIMIT`: 3}"},
+		{in: "-1 - -2", out: "-1 - -2"},
+		{in: "a = [1, 2] + [3]", out: "a = [1, 2] + [3]"},
+	} {
+		q, err := cypher.ParseQuery("MATCH (a) WHERE " + query.in + " RETURN *")
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		out := "MATCH (a) WHERE " + query.out + " RETURN *"
+		if strings.Trim(q.String(), " ") != out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", out, q)
+		}
+	}
+}
+
+func TestParseLiteralErrors(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  "MATCH (a) WHERE 9223372036854775808 RETURN *",
+			err: "integer literal 9223372036854775808 is out of range at line 1, char 17",
+		},
+		{
+			in:  "MATCH (a) WHERE -9223372036854775809 RETURN *",
+			err: "integer literal -9223372036854775809 is out of range at line 1, char 18",
+		},
+		{
+			in:  "MATCH (a) WHERE [1, 2 RETURN *",
+			err: "found RETURN, expected ,, ] at line 1, char 23",
+		},
+		{
+			in:  "MATCH (a) WHERE {a: 1, a: 2} RETURN *",
+			err: "duplicate property key a at line 1, char 24",
+		},
+	} {
+		_, err := cypher.ParseQuery(query.in)
+		if err == nil {
+			t.Errorf("%s: expected error %q", query.in, query.err)
+		} else if err.Error() != query.err {
+			t.Errorf("%s:\nExpected:\n\t%s\nGot:\n\t%s", query.in, query.err, err)
+		}
+	}
+}
+
+func TestParseParameters(t *testing.T) {
+	in := "MATCH (u :User $props)-[r {since: $since}]->(f)\nWHERE u = $0 OR f = $`the user` OR u = $props RETURN *"
+	q, err := cypher.ParseQuery(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := "MATCH (u :User $props)-[r {since: $since}]->(f) WHERE u = $0 OR f = $`the user` OR u = $props RETURN *"
+	if strings.Trim(q.String(), " ") != out {
+		t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", out, q)
+	}
+
+	exp := []cypher.ParameterRef{
+		{Name: "props", Positions: []cypher.Pos{{Line: 0, Char: 15}, {Line: 1, Char: 39}}},
+		{Name: "since", Positions: []cypher.Pos{{Line: 0, Char: 34}}},
+		{Name: "0", Positions: []cypher.Pos{{Line: 1, Char: 10}}},
+		{Name: "the user", Positions: []cypher.Pos{{Line: 1, Char: 20}}},
+	}
+	if act := q.Parameters(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("\nExpected:\n\t%v\nGot:\n\t%v", exp, act)
+	}
+}
+
+func TestParseReturn(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "MATCH (n) RETURN n",
+			out: "MATCH (n) RETURN n",
+		},
+		{
+			in:  "MATCH (n) RETURN DISTINCT n AS node, n",
+			out: "MATCH (n) RETURN DISTINCT n AS node, n",
+		},
+		{
+			in:  "MATCH (n) RETURN *, 1 + 2 AS three",
+			out: "MATCH (n) RETURN *, 1 + 2 AS three",
+		},
+		{
+			in:  "RETURN 1",
+			out: "RETURN 1",
+		},
+		{
+			in:  "MATCH (a) RETURN a ORDER BY a ASC, b DESCENDING, c DESC",
+			out: "MATCH (a) RETURN a ORDER BY a, b DESC, c DESC",
+		},
+		{
+			in:  "MATCH (a) RETURN a SKIP 10 LIMIT $limit",
+			out: "MATCH (a) RETURN a SKIP 10 LIMIT $limit",
+		},
+		{
+			in:  "MATCH (a) RETURN a ORDER BY a LIMIT 1 + 1",
+			out: "MATCH (a) RETURN a ORDER BY a LIMIT 1 + 1",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+}
+
+func TestParseReturnErrors(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  "MATCH (n) RETURN",
+			err: "found EOF, expected expression at line 1, char 18",
+		},
+		{
+			in:  "MATCH (n) RETURN n AS",
+			err: "found EOF, expected Variable at line 1, char 23",
+		},
+		{
+			in:  "MATCH (n) RETURN n ORDER n",
+			err: "found n, expected BY at line 1, char 26",
+		},
+	} {
+		_, err := cypher.ParseQuery(query.in)
+		if err == nil {
+			t.Errorf("%s: expected error %q", query.in, query.err)
+		} else if err.Error() != query.err {
+			t.Errorf("%s:\nExpected:\n\t%s\nGot:\n\t%s", query.in, query.err, err)
+		}
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "MATCH (a) WITH a RETURN a",
+			out: "MATCH (a) WITH a RETURN a",
+		},
+		{
+			in:  "WITH 1 AS one RETURN one",
+			out: "WITH 1 AS one RETURN one",
+		},
+		{
+			in:  "MATCH (a)-->(b) WITH DISTINCT a, b AS friend ORDER BY a DESC SKIP 1 LIMIT 5 WHERE a <> friend MATCH (friend)-->(c) RETURN c",
+			out: "MATCH (a)-->(b) WITH DISTINCT a, b AS friend ORDER BY a DESC SKIP 1 LIMIT 5 WHERE a <> friend MATCH (friend)-->(c) RETURN c",
+		},
+		{
+			in:  "MATCH (a) WITH * WITH a WHERE a = 1 OPTIONAL MATCH (a)--(b) RETURN b",
+			out: "MATCH (a) WITH * WITH a WHERE a = 1 OPTIONAL MATCH (a)--(b) RETURN b",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("MATCH (a) WITH a AS b MATCH (b)-->(c) RETURN c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(q.Root.Parts); n != 1 {
+		t.Fatalf("expected 1 part, got %d", n)
+	}
+	if n := q.Root.Parts[0].With.Items[0].Name(); n != "b" {
+		t.Errorf("expected WITH to project b, got %s", n)
+	}
+	if n := len(q.Root.Reading); n != 1 {
+		t.Errorf("expected 1 reading clause in the final part, got %d", n)
+	}
+}
+
+func TestParseUnwind(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "UNWIND $rows AS row RETURN row",
+			out: "UNWIND $rows AS row RETURN row",
+		},
+		{
+			in:  "UNWIND [1, 2, 3] AS x UNWIND [x, x] AS y RETURN y",
+			out: "UNWIND [1, 2, 3] AS x UNWIND [x, x] AS y RETURN y",
+		},
+		{
+			in:  "MATCH (a) UNWIND a AS b MATCH (b)-->(c) WITH c UNWIND c AS d RETURN d",
+			out: "MATCH (a) UNWIND a AS b MATCH (b)-->(c) WITH c UNWIND c AS d RETURN d",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	if _, err := cypher.ParseQuery("UNWIND $rows row RETURN row"); err == nil || err.Error() != "found row, expected AS at line 1, char 14" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseUpdatingClauses(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "CREATE (a :Person {name: $name})",
+			out: "CREATE (a :Person {name: $name})",
+		},
+		{
+			in:  "MATCH (a), (b) CREATE (a)-[:KNOWS]->(b), p = (b)-[:KNOWS]->(a) RETURN p",
+			out: "MATCH (a), (b) CREATE (a)-[:KNOWS]->(b), p = (b)-[:KNOWS]->(a) RETURN p",
+		},
+		{
+			in:  "MERGE (a :Person {id: $id}) ON CREATE SET a.created = $now, a.visits = 1 ON MATCH SET a.visits = $visits + 1",
+			out: "MERGE (a :Person {id: $id}) ON CREATE SET a.created = $now, a.visits = 1 ON MATCH SET a.visits = $visits + 1",
+		},
+		{
+			in:  "MATCH (n) SET n = $props, n += {active: true}, n:Active:Checked, n.address.city = 'Berlin'",
+			out: "MATCH (n) SET n = $props, n += {active: true}, n:Active:Checked, n.address.city = \"Berlin\"",
+		},
+		{
+			in:  "MATCH (n) REMOVE n.name, n:Active:Checked RETURN n",
+			out: "MATCH (n) REMOVE n.name, n:Active:Checked RETURN n",
+		},
+		{
+			in:  "MATCH (n)-[r]->() DELETE r, n",
+			out: "MATCH (n)-[r]->() DELETE r, n",
+		},
+		{
+			in:  "MATCH (n) DETACH DELETE n",
+			out: "MATCH (n) DETACH DELETE n",
+		},
+		{
+			in:  "MATCH (n) SET n.seen = true WITH n MATCH (n)-->(m) DELETE m",
+			out: "MATCH (n) SET n.seen = true WITH n MATCH (n)-->(m) DELETE m",
+		},
+		{
+			in:  "MATCH (n) SET n.End = 1, n.order = 2",
+			out: "MATCH (n) SET n.End = 1, n.order = 2",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+}
+
+func TestParseUpdatingClausesErrors(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  "MATCH (n)",
+			err: "found EOF, expected RETURN at line 1, char 10",
+		},
+		{
+			in:  "MATCH (n) DETACH n",
+			err: "found n, expected DELETE at line 1, char 18",
+		},
+		{
+			in:  "MERGE (n) ON DELETE SET n.x = 1",
+			err: "found DELETE, expected MATCH, CREATE at line 1, char 14",
+		},
+		{
+			in:  "MERGE (n) ON delete SET n.x = 1",
+			err: "found DELETE, expected MATCH, CREATE at line 1, char 14",
+		},
+		{
+			in:  "MATCH (n) SET n.x += 1",
+			err: "found +=, expected = at line 1, char 19",
+		},
+		{
+			in:  "MATCH (n) REMOVE n",
+			err: "found EOF, expected :, . at line 1, char 20",
+		},
+	} {
+		_, err := cypher.ParseQuery(query.in)
+		if err == nil {
+			t.Errorf("%s: expected error %q", query.in, query.err)
+		} else if err.Error() != query.err {
+			t.Errorf("%s:\nExpected:\n\t%s\nGot:\n\t%s", query.in, query.err, err)
+		}
+	}
+}
+
+func TestParseUnion(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "MATCH (a :A) RETURN a AS x UNION ALL MATCH (b :B) RETURN b AS x",
+			out: "MATCH (a :A) RETURN a AS x UNION ALL MATCH (b :B) RETURN b AS x",
+		},
+		{
+			in:  "RETURN 1 AS x, 2 AS y UNION RETURN 3 AS x, 4 AS y UNION ALL RETURN 5 AS x, 6 AS y",
+			out: "RETURN 1 AS x, 2 AS y UNION RETURN 3 AS x, 4 AS y UNION ALL RETURN 5 AS x, 6 AS y",
+		},
+		{
+			in:  "MATCH (x) RETURN x UNION MATCH (x)-->() RETURN x",
+			out: "MATCH (x) RETURN x UNION MATCH (x)-->() RETURN x",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("RETURN 1 AS x UNION ALL RETURN 2 AS x UNION RETURN 3 AS x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Union) != 2 || !q.Union[0].All || q.Union[1].All {
+		t.Errorf("unexpected union parts: %#v", q.Union)
+	}
+
+	for _, in := range []string{
+		"RETURN 1 AS x UNION RETURN 2 AS y",
+		"RETURN 1 AS x UNION RETURN 2 AS x, 3 AS y",
+		"RETURN 1 AS x UNION CREATE (n)",
+	} {
+		_, err := cypher.ParseQuery(in)
+		if err == nil || !strings.HasPrefix(err.Error(), "all sub queries in an UNION must have the same return column names") {
+			t.Errorf("%s: unexpected error %v", in, err)
+		}
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	script := "CREATE (a :A);\n;\nMATCH (a :A)\nRETURN a;\n  MATCH (b) DELETE b"
+
+	stmts, err := cypher.ParseScript(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []struct {
+		out   string
+		start cypher.Pos
+		end   cypher.Pos
+	}{
+		{out: "CREATE (a :A)", start: cypher.Pos{Line: 0, Char: 0}, end: cypher.Pos{Line: 0, Char: 13}},
+		{out: "MATCH (a :A) RETURN a", start: cypher.Pos{Line: 2, Char: 0}, end: cypher.Pos{Line: 3, Char: 8}},
+		{out: "MATCH (b) DELETE b", start: cypher.Pos{Line: 4, Char: 2}, end: cypher.Pos{Line: 4, Char: 21}},
+	}
+	if len(stmts) != len(exp) {
+		t.Fatalf("expected %d statements, got %d", len(exp), len(stmts))
+	}
+	for i, stmt := range stmts {
+		if stmt.Query.String() != exp[i].out {
+			t.Errorf("%d. Expected:\n\t%s\nGot:\n\t%s", i, exp[i].out, stmt.Query)
+		}
+		if stmt.Start != exp[i].start || stmt.End != exp[i].end {
+			t.Errorf("%d. Expected span %v-%v, got %v-%v", i, exp[i].start, exp[i].end, stmt.Start, stmt.End)
+		}
+	}
+}
+
+func TestParserNext(t *testing.T) {
+	p := cypher.NewParser(strings.NewReader("RETURN $a; RETURN $b;"))
+
+	for _, name := range []string{"a", "b"} {
+		stmt, err := p.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if params := stmt.Query.Parameters(); len(params) != 1 || params[0].Name != name {
+			t.Errorf("expected only parameter %s, got %v", name, params)
+		}
+	}
+	if _, err := p.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	if _, err := cypher.NewParser(strings.NewReader("RETURN 1 RETURN 2")).Next(); err == nil || err.Error() != "found RETURN, expected ;, EOF at line 1, char 10" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if q, err := cypher.ParseQuery("RETURN $a; RETURN $b;"); err != nil {
+		t.Fatal(err)
+	} else if q.String() != "RETURN $b" {
+		t.Errorf("expected the last statement, got %s", q)
+	} else if params := q.Parameters(); len(params) != 1 || params[0].Name != "b" {
+		t.Errorf("expected only parameter b, got %v", params)
+	}
+}
+
+func TestParserNextBounded(t *testing.T) {
+	const stmt = "UNWIND [1, 2, 3] AS x RETURN [y IN [x, (x + 1)] | y];\n"
+	p := cypher.NewParser(strings.NewReader(strings.Repeat(stmt, 1000)))
+
+	var n, max int
+	for {
+		if _, err := p.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if size := cypher.MemoSize(p); size > max {
+			max = size
+		}
+	}
+	if n != 1000 {
+		t.Errorf("expected 1000 statements, got %d", n)
+	}
+	if max > 10 {
+		t.Errorf("expected the parser to only remember the current statement, got %d offsets", max)
+	}
+}
+
+func TestParseCall(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "CALL db.labels()",
+			out: "CALL db.labels()",
+		},
+		{
+			in:  "CALL db.labels",
+			out: "CALL db.labels",
+		},
+		{
+			in:  "CALL db.labels() YIELD *",
+			out: "CALL db.labels() YIELD *",
+		},
+		{
+			in:  "CALL db.index.fulltext.queryNodes('people', $q) YIELD node, score AS s WHERE s > 1 RETURN node",
+			out: "CALL db.index.fulltext.queryNodes(\"people\", $q) YIELD node, score AS s WHERE s > 1 RETURN node",
+		},
+		{
+			in:  "MATCH (n) CALL apoc.create.addLabels(n, ['A']) YIELD node RETURN node",
+			out: "MATCH (n) CALL apoc.create.addLabels(n, [\"A\"]) YIELD node RETURN node",
+		},
+		{
+			in:  "MATCH (n) CALL my.proc(n)",
+			out: "MATCH (n) CALL my.proc(n)",
+		},
+		{
+			in:  "CALL apoc.Create.Node(['A'], {}) YIELD node RETURN node",
+			out: "CALL apoc.Create.Node([\"A\"], {}) YIELD node RETURN node",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("CALL apoc.Do.when(true, 'RETURN 1') YIELD value RETURN value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := q.Root.Reading[0].Call.ProcedureName(); name != "apoc.Do.when" {
+		t.Errorf("unexpected procedure name %s", name)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "MATCH (n) CALL my.proc(n) YIELD x", err: "found EOF, expected RETURN at line 1, char 35"},
+		{in: "CALL db.labels(", err: "found EOF, expected expression at line 1, char 16"},
+		{in: "CALL db.labels() YIELD 1", err: "found 1, expected Field at line 1, char 24"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParseSubqueryCall(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "MATCH (a) CALL { WITH a MATCH (a)-->(b) RETURN b } RETURN a, b",
+			out: "MATCH (a) CALL { WITH a MATCH (a)-->(b) RETURN b } RETURN a, b",
+		},
+		{
+			in:  "MATCH (a) CALL (a) { MATCH (a)-->(b) RETURN b UNION MATCH (a)<--(b) RETURN b } RETURN b",
+			out: "MATCH (a) CALL (a) { MATCH (a)-->(b) RETURN b UNION MATCH (a)<--(b) RETURN b } RETURN b",
+		},
+		{
+			in:  "MATCH (a) CALL (*) { CREATE (b) } RETURN a",
+			out: "MATCH (a) CALL (*) { CREATE (b) } RETURN a",
+		},
+		{
+			in:  "CALL () { RETURN 1 AS one } RETURN one",
+			out: "CALL () { RETURN 1 AS one } RETURN one",
+		},
+		{
+			in:  "UNWIND $rows AS row CALL { WITH row CREATE (n $row) } IN TRANSACTIONS OF 1000 ROWS",
+			out: "UNWIND $rows AS row CALL { WITH row CREATE (n $row) } IN TRANSACTIONS OF 1000 ROWS",
+		},
+		{
+			in:  "UNWIND $rows AS row CALL (row) { CREATE (n $row) } IN 4 CONCURRENT TRANSACTIONS OF 1 ROW ON ERROR CONTINUE REPORT STATUS AS s RETURN s",
+			out: "UNWIND $rows AS row CALL (row) { CREATE (n $row) } IN 4 CONCURRENT TRANSACTIONS OF 1 ROWS ON ERROR CONTINUE REPORT STATUS AS s RETURN s",
+		},
+		{
+			in:  "UNWIND $rows AS row CALL { WITH row CREATE (n $row) } in transactions on error break",
+			out: "UNWIND $rows AS row CALL { WITH row CREATE (n $row) } IN TRANSACTIONS ON ERROR BREAK",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("UNWIND $rows AS row CALL { WITH row, 1 AS x CREATE (n $row) } IN TRANSACTIONS OF $batch ROWS ON ERROR FAIL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := q.Root.Reading[1].Subquery
+	if imports := sc.Imports(); !reflect.DeepEqual(imports, []cypher.Variable{"row"}) {
+		t.Errorf("unexpected imports %v", imports)
+	}
+	if opts := sc.InTransactions; opts == nil || opts.BatchSize == nil || *opts.BatchSize != cypher.Expr(cypher.Parameter("batch")) || opts.OnError != cypher.OnErrorFail {
+		t.Errorf("unexpected transaction options %#v", opts)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "CALL { CREATE (n) } IN TRANSACTIONS OF 10", err: "found EOF, expected ROWS at line 1, char 42"},
+		{in: "CALL { CREATE (n) } IN TRANSACTIONS ON ERROR RETRY", err: "found RETRY, expected CONTINUE, BREAK, FAIL at line 1, char 46"},
+		{in: "CALL { CREATE (n) RETURN", err: "found EOF, expected expression at line 1, char 26"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParseForeach(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "MATCH (n) FOREACH (x IN $list | SET n.flag = true)",
+			out: "MATCH (n) FOREACH (x IN $list | SET n.flag = true)",
+		},
+		{
+			in:  "MATCH (a) FOREACH (x IN [1, 2] | CREATE (a)-[:R]->(b {n: x}) FOREACH (y IN [x] | MERGE (c {n: y})))",
+			out: "MATCH (a) FOREACH (x IN [1, 2] | CREATE (a)-[:R]->(b {n: x}) FOREACH (y IN [x] | MERGE (c {n: y})))",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "FOREACH (x IN $list SET x.a = 1)", err: "found SET, expected | at line 1, char 21"},
+		{in: "FOREACH (x IN $list | RETURN x)", err: "found RETURN, expected CREATE, MERGE, SET, REMOVE, DELETE, FOREACH at line 1, char 23"},
+		{in: "FOREACH (x IN $list | DELETE x", err: "found EOF, expected ) at line 1, char 32"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParseCase(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  "RETURN CASE x WHEN 1 THEN 'one' WHEN 2 THEN 'two' ELSE 'many' END AS n",
+			out: "RETURN CASE x WHEN 1 THEN \"one\" WHEN 2 THEN \"two\" ELSE \"many\" END AS n",
+		},
+		{
+			in:  "RETURN CASE WHEN a > 1 AND b THEN a END + 1",
+			out: "RETURN CASE WHEN a > 1 AND b THEN a END + 1",
+		},
+		{
+			in:  "RETURN CASE x + 1 WHEN CASE WHEN y THEN 1 END THEN true END",
+			out: "RETURN CASE x + 1 WHEN CASE WHEN y THEN 1 END THEN true END",
+		},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "RETURN CASE x WHEN 1 THEN 2 RETURN", err: "found RETURN, expected WHEN, ELSE, END at line 1, char 29"},
+		{in: "RETURN CASE WHEN a THEN 1 ELSE 2", err: "found EOF, expected END at line 1, char 33"},
+		{in: "RETURN CASE x END", err: "found END, expected WHEN at line 1, char 15"},
+		{in: "RETURN CASE WHEN a 1 END", err: "found 1, expected THEN at line 1, char 20"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParseFunctionCalls(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "RETURN count(*)", out: "RETURN count(*)"},
+		{in: "RETURN count(DISTINCT n) AS c", out: "RETURN count(DISTINCT n) AS c"},
+		{in: "RETURN toLower(n.name)", out: "RETURN toLower(n.name)"},
+		{in: "RETURN apoc.text.join(list, ',')", out: "RETURN apoc.text.join(list, \",\")"},
+		{in: "RETURN apoc.create.uuid()", out: "RETURN apoc.create.uuid()"},
+		{in: "RETURN timestamp() - coalesce(n.at, 0) * 2", out: "RETURN timestamp() - coalesce(n.at, 0) * 2"},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("RETURN Count(DISTINCT n), my.count(n), size(n)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, exp := range []bool{true, false, false} {
+		fc, ok := q.Root.ReturnItems[i].Expr.(cypher.FunctionCall)
+		if !ok {
+			t.Fatalf("%d. expected a function call, got %#v", i, q.Root.ReturnItems[i].Expr)
+		}
+		if fc.IsAggregate() != exp {
+			t.Errorf("%s: expected IsAggregate to be %t", fc, exp)
+		}
+	}
+
+	if _, err := cypher.ParseQuery("RETURN count(a b)"); err == nil || err.Error() != "found b, expected ,, ) at line 1, char 16" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParsePostfixExpressions(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "n.name", out: "n.name"},
+		{in: "n.address.city", out: "n.address.city"},
+		{in: "n.`first name`", out: "n.`first name`"},
+		{in: "list[0]", out: "list[0]"},
+		{in: "map['key']", out: "map[\"key\"]"},
+		{in: "list[1..3]", out: "list[1..3]"},
+		{in: "list[..-1]", out: "list[..-1]"},
+		{in: "list[$from..]", out: "list[$from..]"},
+		{in: "n.tags[0][1..].length", out: "n.tags[0][1..].length"},
+		{in: "[1, 2, 3][i + 1]", out: "[1, 2, 3][i + 1]"},
+		{in: "{a: {b: 1}}.a.b", out: "{a: {b: 1}}.a.b"},
+		{in: "(a + b).c", out: "(a + b).c"},
+		{in: "-n.x ^ 2", out: "-n.x ^ 2"},
+		{in: "head(n.list).name", out: "head(n.list).name"},
+		{in: "n.end", out: "n.end"},
+		{in: "n.END + n.Order", out: "n.END + n.Order"},
+	} {
+		q, err := cypher.ParseQuery("MATCH (n) WHERE " + query.in + " RETURN n")
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		out := "MATCH (n) WHERE " + query.out + " RETURN n"
+		if q.String() != out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("RETURN n.a[0].b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := cypher.PropertyAccess{
+		Expr: cypher.IndexExpr{
+			Expr:  cypher.PropertyAccess{Expr: cypher.Variable("n"), Key: "a"},
+			Index: cypher.IntegerLiteral(0),
+		},
+		Key: "b",
+	}
+	if act := q.Root.ReturnItems[0].Expr; !reflect.DeepEqual(act, cypher.Expr(exp)) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, act)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "RETURN list[1 2]", err: "found 2, expected ], .. at line 1, char 15"},
+		{in: "RETURN list[1..2", err: "found EOF, expected ] at line 1, char 17"},
+		{in: "RETURN (n).1", err: "found 1, expected Property Key at line 1, char 12"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParsePredicates(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "n.name STARTS WITH 'A'", out: `n.name STARTS WITH "A"`},
+		{in: "n.name ends  with $suffix", out: "n.name ENDS WITH $suffix"},
+		{in: "n.name CONTAINS 'x' AND n.age > 3", out: `n.name CONTAINS "x" AND n.age > 3`},
+		{in: "n.name =~ '(?i)jo.*'", out: `n.name =~ "(?i)jo.*"`},
+		{in: "n.id IN [1, 2, 3]", out: "n.id IN [1, 2, 3]"},
+		{in: "n.id IN $ids = true", out: "n.id IN $ids = true"},
+		{in: "n.a + 1 IN list", out: "n.a + 1 IN list"},
+		{in: "(n.a = 1) IN list", out: "(n.a = 1) IN list"},
+		{in: "n.email IS NULL", out: "n.email IS NULL"},
+		{in: "n.email IS NOT NULL OR n.phone IS NOT NULL", out: "n.email IS NOT NULL OR n.phone IS NOT NULL"},
+		{in: "NOT n.email IS NULL", out: "NOT n.email IS NULL"},
+		{in: "n.a IN [1] IN [true]", out: "n.a IN [1] IN [true]"},
+		{in: "n.a IN ([1] IN [true])", out: "n.a IN ([1] IN [true])"},
+		{in: "(n.a IS NULL) IS NULL", out: "n.a IS NULL IS NULL"},
+	} {
+		q, err := cypher.ParseQuery("MATCH (n) WHERE " + query.in + " RETURN n")
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		out := "MATCH (n) WHERE " + query.out + " RETURN n"
+		if q.String() != out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("RETURN a = b STARTS WITH c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := cypher.BinaryExpr{
+		Op:  cypher.EQ,
+		LHS: cypher.Variable("a"),
+		RHS: cypher.StringPredicate{Op: cypher.STARTS, LHS: cypher.Variable("b"), RHS: cypher.Variable("c")},
+	}
+	if act := q.Root.ReturnItems[0].Expr; !reflect.DeepEqual(act, cypher.Expr(exp)) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, act)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "RETURN a STARTS 'x'", err: "found x, expected WITH at line 1, char 16"},
+		{in: "RETURN a IS 1", err: "found 1, expected NOT, NULL at line 1, char 13"},
+		{in: "RETURN a IS NOT true", err: "found TRUE, expected NULL at line 1, char 17"},
+		{in: "RETURN a IN", err: "found EOF, expected expression at line 1, char 13"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParseComprehensions(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "RETURN [x IN list WHERE x > 0 | x * 2]", out: "RETURN [x IN list WHERE x > 0 | x * 2]"},
+		{in: "RETURN [x IN range(1, 10) | x ^ 2]", out: "RETURN [x IN range(1, 10) | x ^ 2]"},
+		{in: "RETURN [ x  IN  [1, 2]  WHERE x IS NOT NULL ]", out: "RETURN [x IN [1, 2] WHERE x IS NOT NULL]"},
+		{in: "RETURN [x IN list]", out: "RETURN [x IN list]"},
+		{in: "RETURN [x, y IN list]", out: "RETURN [x, y IN list]"},
+		{in: "RETURN [(1 + 2) * 3, (a)]", out: "RETURN [(1 + 2) * 3, a]"},
+		{in: "RETURN [a = 1, b]", out: "RETURN [a = 1, b]"},
+		{in: "MATCH (a) RETURN [(a)-->(b) | b.name]", out: "MATCH (a) RETURN [(a)-->(b) | b.name]"},
+		{in: "MATCH (a) RETURN [p = (a)-[:KNOWS*1..2]->(b:User) WHERE b.age > 18 | length(p)]", out: "MATCH (a) RETURN [p = (a)-[:KNOWS*1..2]->(b :User) WHERE b.age > 18 | length(p)]"},
+		{in: "MATCH (a) WHERE all(x IN a.scores WHERE x > 10) RETURN a", out: "MATCH (a) WHERE all(x IN a.scores WHERE x > 10) RETURN a"},
+		{in: "MATCH (a) WHERE ANY(x IN a.tags WHERE x = 'admin') RETURN a", out: `MATCH (a) WHERE any(x IN a.tags WHERE x = "admin") RETURN a`},
+		{in: "RETURN none(x IN $list WHERE x < 0), single(x IN $list WHERE x = 0)", out: "RETURN none(x IN $list WHERE x < 0), single(x IN $list WHERE x = 0)"},
+		{in: "RETURN reduce(acc = 0, x IN [1, 2, 3] | acc + x) AS total", out: "RETURN reduce(acc = 0, x IN [1, 2, 3] | acc + x) AS total"},
+		{in: "RETURN [x IN [y IN list | y + 1] WHERE x > 2][0]", out: "RETURN [x IN [y IN list | y + 1] WHERE x > 2][0]"},
+		{in: "MATCH p = (a)-->(b) RETURN [x IN nodes(p) WHERE x:Person | x.name]", out: "MATCH p = (a)-->(b) RETURN [x IN nodes(p) WHERE x:Person | x.name]"},
+		{in: "MATCH (a) RETURN [(a)-->(b) WHERE b:Person | b.name]", out: "MATCH (a) RETURN [(a)-->(b) WHERE b:Person | b.name]"},
+		{in: "RETURN [x IN [n] WHERE x:A | 1]", out: "RETURN [x IN [n] WHERE x:A | 1]"},
+		{in: "RETURN [x IN list WHERE x:A | x]", out: "RETURN [x IN list WHERE x:A | x]"},
+		{in: "RETURN [x IN list WHERE x:A|B AND x.y | x]", out: "RETURN [x IN list WHERE x:A|B AND x.y | x]"},
+		{in: "RETURN [x IN list WHERE x:(A|B) | x]", out: "RETURN [x IN list WHERE x:(A|B) | x]"},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("RETURN [x IN list WHERE x > 0]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := cypher.ListComprehension{
+		Variable: cypher.Variable("x"),
+		List:     cypher.Variable("list"),
+		Where:    cypher.BinaryExpr{Op: cypher.GT, LHS: cypher.Variable("x"), RHS: cypher.IntegerLiteral(0)},
+	}
+	if act := q.Root.ReturnItems[0].Expr; !reflect.DeepEqual(act, cypher.Expr(exp)) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, act)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "RETURN [x IN list | ]", err: "found ], expected expression at line 1, char 21"},
+		{in: "RETURN [x IN list WHERE x > 1 x]", err: "found x, expected ] at line 1, char 31"},
+		{in: "RETURN [(a)-->(b) WHERE b.x b.name]", err: "found b, expected | at line 1, char 29"},
+		{in: "RETURN all(x)", err: "found x, expected Variable IN at line 1, char 12"},
+		{in: "RETURN any(x IN list WHERE x > 1", err: "found EOF, expected ) at line 1, char 33"},
+		{in: "RETURN reduce(acc, x IN list | acc)", err: "found ,, expected = at line 1, char 18"},
+		{in: "RETURN reduce(acc = 0, x IN list)", err: "found ), expected | at line 1, char 33"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParseMapProjections(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "RETURN n { .name, .email, friends: collect(f.name), .* }", out: "RETURN n {.name, .email, friends: collect(f.name), .*}"},
+		{in: "RETURN n {}", out: "RETURN n {}"},
+		{in: "RETURN n {.`first name`, x, .end}", out: "RETURN n {.`first name`, x, .end}"},
+		{in: "RETURN n {.Order, .LIMIT}", out: "RETURN n {.Order, .LIMIT}"},
+		{in: "RETURN n {.name, limit: 1, End: n.end}", out: "RETURN n {.name, `limit`: 1, `End`: n.end}"},
+		{in: "RETURN n {.name, `limit`: 1}", out: "RETURN n {.name, `limit`: 1}"},
+		{in: "RETURN n {.a, b: {c: 1}}.b", out: "RETURN n {.a, b: {c: 1}}.b"},
+		{in: "WITH n {.*, age: n.age + 1} AS m RETURN m", out: "WITH n {.*, age: n.age + 1} AS m RETURN m"},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("RETURN n {.name, total: 1, x, .*}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := cypher.Variable("x")
+	exp := cypher.MapProjection{
+		Variable: cypher.Variable("n"),
+		Items: []cypher.MapProjectionItem{
+			{Key: "name"},
+			{Key: "total", Value: cypher.IntegerLiteral(1)},
+			{Variable: &x},
+			{AllProperties: true},
+		},
+	}
+	if act := q.Root.ReturnItems[0].Expr; !reflect.DeepEqual(act, cypher.Expr(exp)) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, act)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "RETURN n {.name,}", err: "unexpected trailing comma in map at line 1, char 17"},
+		{in: "RETURN n {.name .email}", err: "found ., expected ,, } at line 1, char 17"},
+		{in: "RETURN n {1}", err: "found 1, expected ., Property Key at line 1, char 11"},
+		{in: "RETURN n {.1}", err: "found 1, expected Property Key at line 1, char 12"},
+		{in: "RETURN n {.name, limit}", err: "found }, expected : at line 1, char 23"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParseSubqueryExpressions(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "MATCH (n) WHERE exists(n.email) RETURN n", out: "MATCH (n) WHERE exists(n.email) RETURN n"},
+		{in: "MATCH (n) WHERE EXISTS { (n)-->(m) } RETURN n", out: "MATCH (n) WHERE EXISTS { (n)-->(m) } RETURN n"},
+		{in: "MATCH (n) WHERE exists {(n)-[:OWNS]->(d), (d)<--(m) WHERE m.admin} RETURN n", out: "MATCH (n) WHERE EXISTS { (n)-[:OWNS]->(d), (d)<--(m) WHERE m.admin } RETURN n"},
+		{in: "MATCH (n) WHERE EXISTS { MATCH (n)-->(m) WHERE m.admin = true } RETURN n", out: "MATCH (n) WHERE EXISTS { MATCH (n)-->(m) WHERE m.admin = true } RETURN n"},
+		{in: "MATCH (n) WHERE NOT EXISTS { MATCH (n)-->(m) RETURN m UNION MATCH (n)<--(m) RETURN m } RETURN n", out: "MATCH (n) WHERE NOT EXISTS { MATCH (n)-->(m) RETURN m UNION MATCH (n)<--(m) RETURN m } RETURN n"},
+		{in: "MATCH (n) RETURN COUNT { (n)-->() } AS degree", out: "MATCH (n) RETURN COUNT { (n)-->() } AS degree"},
+		{in: "MATCH (n) WHERE count { MATCH (n)-->(m) } > 2 RETURN n", out: "MATCH (n) WHERE COUNT { MATCH (n)-->(m) } > 2 RETURN n"},
+		{in: "MATCH (n) RETURN collect { MATCH (n)-->(m) RETURN m.name } AS names", out: "MATCH (n) RETURN COLLECT { MATCH (n)-->(m) RETURN m.name } AS names"},
+		{in: "MATCH (n) RETURN count(n), collect(n.name)", out: "MATCH (n) RETURN count(n), collect(n.name)"},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("RETURN EXISTS { (n) WHERE n.admin }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := cypher.Variable("n")
+	exp := cypher.SubqueryExpr{
+		Kind:    cypher.SubqueryExists,
+		Pattern: []cypher.MatchPattern{{Elements: []cypher.PatternElement{&cypher.NodePattern{Variable: &n}}}},
+		Where:   cypher.PropertyAccess{Expr: n, Key: "admin"},
+	}
+	if act := q.Root.ReturnItems[0].Expr; !reflect.DeepEqual(act, cypher.Expr(exp)) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, act)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "RETURN EXISTS n", err: "found n, expected (, { at line 1, char 15"},
+		{in: "RETURN EXISTS { (n)-->(m) RETURN m }", err: "found RETURN, expected } at line 1, char 27"},
+		{in: "RETURN COLLECT { MATCH (n) }", err: "found }, expected RETURN at line 1, char 28"},
+		{in: "RETURN COUNT { MATCH (n) ", err: "found EOF, expected } at line 1, char 27"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParsePatternPredicates(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "MATCH (a), (b) WHERE (a)-[:FOLLOWS]->(b) RETURN a", out: "MATCH (a), (b) WHERE (a)-[:FOLLOWS]->(b) RETURN a"},
+		{in: "MATCH (a) WHERE NOT (a)--(:Blocked) RETURN a", out: "MATCH (a) WHERE NOT (a)--( :Blocked) RETURN a"},
+		{in: "MATCH (a) WHERE ( a )<-[:X]-( b {id: 1} ) AND a.x > 1 RETURN a", out: "MATCH (a) WHERE (a)<-[:X]-(b {id: 1}) AND a.x > 1 RETURN a"},
+		{in: "MATCH (a) WHERE (a.x) > 1 OR ( 1 + 2 ) = a.y RETURN a", out: "MATCH (a) WHERE a.x > 1 OR 1 + 2 = a.y RETURN a"},
+		{in: "MATCH (a) WHERE (a) - (b) > 0 RETURN a", out: "MATCH (a) WHERE a - b > 0 RETURN a"},
+		{in: "MATCH (a) RETURN [(a)-->(), (b)] AS l", out: "MATCH (a) RETURN [(a)-->(), b] AS l"},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("MATCH (a) WHERE NOT (a)-->(b) RETURN a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := cypher.Variable("a"), cypher.Variable("b")
+	exp := cypher.UnaryExpr{
+		Op: cypher.NOT,
+		Expr: cypher.PatternPredicate{Pattern: cypher.MatchPattern{Elements: []cypher.PatternElement{
+			&cypher.NodePattern{Variable: &a},
+			&cypher.EdgePattern{Direction: cypher.EdgeRight},
+			&cypher.NodePattern{Variable: &b},
+		}}},
+	}
+	if act := *q.Root.Reading[0].Where; !reflect.DeepEqual(act, cypher.Expr(exp)) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, act)
+	}
+}
+
+func TestParseDeeplyNested(t *testing.T) {
+	const depth = 30
+	for _, query := range []string{
+		"RETURN " + strings.Repeat("({k: ", depth) + "1" + strings.Repeat("})", depth),
+		"MATCH " + strings.Repeat("(a {k: ", depth) + "1" + strings.Repeat("})", depth) + " RETURN a",
+		"RETURN " + strings.Repeat("(", depth) + "a" + strings.Repeat(")", depth),
+		"RETURN " + strings.Repeat("[({k: ", depth) + "1" + strings.Repeat("})]", depth),
+		"RETURN " + strings.Repeat("[(a)-->(b {k: ", depth) + "1" + strings.Repeat("}) | 1]", depth),
+	} {
+		start := time.Now()
+		if _, err := cypher.ParseQuery(query); err != nil {
+			t.Errorf("%s: %s", query, err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("%s: parsing took %s", query, d)
+		}
+	}
+}
+
+func TestParsePathSelectors(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "MATCH p = shortestPath((a)-[*..10]-(b)) RETURN p", out: "MATCH p = shortestPath((a)-[*..10]-(b)) RETURN p"},
+		{in: "MATCH p = allShortestPaths( (a:User)-[:KNOWS*]->(b) ) RETURN p", out: "MATCH p = allShortestPaths((a :User)-[:KNOWS*]->(b)) RETURN p"},
+		{in: "MATCH SHORTESTPATH((a)-->(b)) RETURN a", out: "MATCH shortestPath((a)-->(b)) RETURN a"},
+		{in: "MATCH p = ANY SHORTEST (a)-[*]-(b) RETURN p", out: "MATCH p = ANY SHORTEST (a)-[*]-(b) RETURN p"},
+		{in: "MATCH p = all shortest (a)-[*]-(b) RETURN p", out: "MATCH p = ALL SHORTEST (a)-[*]-(b) RETURN p"},
+		{in: "MATCH p = SHORTEST 3 (a)-[*]-(b) RETURN p", out: "MATCH p = SHORTEST 3 (a)-[*]-(b) RETURN p"},
+		{in: "MATCH SHORTEST 2 GROUPS (a)-[*]-(b) RETURN a", out: "MATCH SHORTEST 2 GROUPS (a)-[*]-(b) RETURN a"},
+		{in: "MATCH p = ANY SHORTEST PATH (a)-[*]-(b) RETURN p", out: "MATCH p = ANY SHORTEST (a)-[*]-(b) RETURN p"},
+		{in: "MATCH p = ALL SHORTEST PATHS (a)-[*]-(b) RETURN p", out: "MATCH p = ALL SHORTEST (a)-[*]-(b) RETURN p"},
+		{in: "MATCH p = SHORTEST 2 PATHS (a)-[*]-(b) RETURN p", out: "MATCH p = SHORTEST 2 (a)-[*]-(b) RETURN p"},
+		{in: "MATCH p = SHORTEST 2 PATH GROUPS (a)-[*]-(b) RETURN p", out: "MATCH p = SHORTEST 2 GROUPS (a)-[*]-(b) RETURN p"},
+		{in: "OPTIONAL MATCH p = any shortest path trail (a)-[*]-(b) RETURN p", out: "OPTIONAL MATCH p = ANY SHORTEST TRAIL (a)-[*]-(b) RETURN p"},
+		{in: "MATCH any = (a)-->(b) RETURN any", out: "MATCH any = (a)-->(b) RETURN any"},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("MATCH p = SHORTEST 2 GROUPS (a) RETURN p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := &cypher.PathSelector{Kind: cypher.ShortestKGroups, Count: 2}
+	if act := q.Root.Reading[0].Pattern[0].Selector; !reflect.DeepEqual(act, exp) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, act)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "MATCH p = shortestPath(a)-->(b)) RETURN p", err: "found a, expected ( at line 1, char 24"},
+		{in: "MATCH p = shortestPath((a)-->(b) RETURN p", err: "found RETURN, expected ) at line 1, char 34"},
+		{in: "MATCH p = ANY (a)-->(b) RETURN p", err: "found (, expected SHORTEST at line 1, char 15"},
+		{in: "MATCH p = SHORTEST k (a)-->(b) RETURN p", err: "found k, expected Integer at line 1, char 20"},
+		{in: "CREATE p = ANY SHORTEST (a)-->(b)", err: "ANY SHORTEST is only allowed in MATCH at line 1, char 12"},
+		{in: "MATCH (a), (b) MERGE shortestPath((a)-->(b))", err: "shortestPath is only allowed in MATCH at line 1, char 22"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParseQuantifiedPatterns(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "MATCH ((a)-[:NEXT]->(b)){1,5} RETURN a", out: "MATCH ((a)-[:NEXT]->(b)){1,5} RETURN a"},
+		{in: "MATCH ((a)-->(b))+ RETURN a", out: "MATCH ((a)-->(b))+ RETURN a"},
+		{in: "MATCH ((a)-->(b))* RETURN a", out: "MATCH ((a)-->(b))* RETURN a"},
+		{in: "MATCH ((a)-->(b)){ 3 } RETURN a", out: "MATCH ((a)-->(b)){3} RETURN a"},
+		{in: "MATCH ((a)-->(b)){2,} RETURN a", out: "MATCH ((a)-->(b)){2,} RETURN a"},
+		{in: "MATCH ((a)-->(b)){,4} RETURN a", out: "MATCH ((a)-->(b)){,4} RETURN a"},
+		{in: "MATCH (s) ((a)-[r]->(b) WHERE r.w > 1){1,3} (t:Last) RETURN s", out: "MATCH (s)((a)-[r]->(b) WHERE r.w > 1){1,3}(t :Last) RETURN s"},
+		{in: "MATCH ( (a)-->(b) ) RETURN a", out: "MATCH (a)-->(b) RETURN a"},
+		{in: "MATCH ( ( (a) ) ) RETURN a", out: "MATCH (a) RETURN a"},
+		{in: "MATCH p = TRAIL (a)-[*]->(b) RETURN p", out: "MATCH p = TRAIL (a)-[*]->(b) RETURN p"},
+		{in: "MATCH p = ANY SHORTEST acyclic (a)((x)-->(y))+(b) RETURN p", out: "MATCH p = ANY SHORTEST ACYCLIC (a)((x)-->(y))+(b) RETURN p"},
+		{in: "MATCH walk (a)-->(b) RETURN a", out: "MATCH WALK (a)-->(b) RETURN a"},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("MATCH ((a)-->(b)){2,} RETURN a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, two := cypher.Variable("a"), cypher.Variable("b"), 2
+	exp := []cypher.PatternElement{&cypher.QuantifiedPattern{
+		Elements: []cypher.PatternElement{
+			&cypher.NodePattern{Variable: &a},
+			&cypher.EdgePattern{Direction: cypher.EdgeRight},
+			&cypher.NodePattern{Variable: &b},
+		},
+		Quantifier: &cypher.PathQuantifier{Min: &two},
+	}}
+	if act := q.Root.Reading[0].Pattern[0].Elements; !reflect.DeepEqual(act, exp) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, act)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "MATCH ((a)-->(b)){} RETURN a", err: "found }, expected Integer, , at line 1, char 19"},
+		{in: "MATCH ((a)-->(b)){1 2} RETURN a", err: "found 2, expected } at line 1, char 21"},
+		{in: "MATCH ((a)-->(b) a) RETURN a", err: "found a, expected ) at line 1, char 18"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestParseLabelExpressions(t *testing.T) {
+	for _, query := range []struct {
+		in  string
+		out string
+	}{
+		{in: "MATCH (n:Person&!Banned) RETURN n", out: "MATCH (n :Person&!Banned) RETURN n"},
+		{in: "MATCH (n:A|B) RETURN n", out: "MATCH (n :A|B) RETURN n"},
+		{in: "MATCH (n:%) RETURN n", out: "MATCH (n :%) RETURN n"},
+		{in: "MATCH (n:A:B) RETURN n", out: "MATCH (n :A :B) RETURN n"},
+		{in: "MATCH (n:A&B) RETURN n", out: "MATCH (n :A :B) RETURN n"},
+		{in: "MATCH (n:(A|B)&!(C&%)) RETURN n", out: "MATCH (n :(A|B)&!(C&%)) RETURN n"},
+		{in: "MATCH (n:`Special Label`) RETURN n", out: "MATCH (n :`Special Label`) RETURN n"},
+		{in: "MATCH (a)-[r:KNOWS|:FOLLOWS]->(b) RETURN r", out: "MATCH (a)-[r:KNOWS|FOLLOWS]->(b) RETURN r"},
+		{in: "MATCH (a)-[r:!KNOWS]->(b) RETURN r", out: "MATCH (a)-[r:!KNOWS]->(b) RETURN r"},
+		{in: "MATCH (n) WHERE n:A|B RETURN n", out: "MATCH (n) WHERE n:A|B RETURN n"},
+		{in: "MATCH (n) WHERE n:A:B AND NOT n:C RETURN n", out: "MATCH (n) WHERE n:A:B AND NOT n:C RETURN n"},
+		{in: "MATCH (n) WHERE n:A|B:C RETURN n", out: "MATCH (n) WHERE n:(A|B)&C RETURN n"},
+		{in: "MATCH (n) RETURN (n:Admin) AS admin", out: "MATCH (n) RETURN n:Admin AS admin"},
+	} {
+		q, err := cypher.ParseQuery(query.in)
+		if err != nil {
+			t.Errorf("%s: %s", query.in, err)
+			continue
+		}
+		if q.String() != query.out {
+			t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", query.out, q)
+		}
+	}
+
+	q, err := cypher.ParseQuery("MATCH (n:Person&!Banned|Admin) RETURN n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := cypher.LabelOr{
+		LHS: cypher.LabelAnd{
+			LHS: cypher.LabelName("Person"),
+			RHS: cypher.LabelNot{Expr: cypher.LabelName("Banned")},
+		},
+		RHS: cypher.LabelName("Admin"),
+	}
+	node := q.Root.Reading[0].Pattern[0].Elements[0].(*cypher.NodePattern)
+	if !reflect.DeepEqual(node.Labels, cypher.LabelExpr(exp)) {
+		t.Errorf("\nExpected:\n\t%#v\nGot:\n\t%#v", exp, node.Labels)
+	}
+
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{in: "MATCH (n:A&) RETURN n", err: "found ), expected Label Identifier at line 1, char 12"},
+		{in: "MATCH (n:(A|B) RETURN n", err: "found RETURN, expected ) at line 1, char 16"},
+		{in: "MATCH ()-[:A|]->() RETURN *", err: "found ], expected Type Identifier at line 1, char 14"},
+	} {
+		if _, err := cypher.ParseQuery(tc.in); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+	}
+}
+
+func TestFlattenLabels(t *testing.T) {
+	for _, tc := range []struct {
+		expr   cypher.LabelExpr
+		labels []string
+		types  []string
+	}{
+		{expr: cypher.LabelName("A"), labels: []string{"A"}, types: []string{"A"}},
+		{
+			expr:   cypher.LabelAnd{LHS: cypher.LabelName("A"), RHS: cypher.LabelGroup{Expr: cypher.LabelAnd{LHS: cypher.LabelName("B"), RHS: cypher.LabelName("C")}}},
+			labels: []string{"A", "B", "C"},
+		},
+		{expr: cypher.LabelOr{LHS: cypher.LabelName("A"), RHS: cypher.LabelName("B")}, types: []string{"A", "B"}},
+		{expr: cypher.LabelAnd{LHS: cypher.LabelName("A"), RHS: cypher.LabelNot{Expr: cypher.LabelName("B")}}},
+		{expr: cypher.LabelWildcard{}},
+		{expr: nil},
+	} {
+		if labels, ok := cypher.FlattenLabels(tc.expr); !reflect.DeepEqual(labels, tc.labels) || ok != (tc.labels != nil) {
+			t.Errorf("FlattenLabels(%v) = %v, %v", tc.expr, labels, ok)
+		}
+		if types, ok := cypher.FlattenTypes(tc.expr); !reflect.DeepEqual(types, tc.types) || ok != (tc.types != nil) {
+			t.Errorf("FlattenTypes(%v) = %v, %v", tc.expr, types, ok)
+		}
+	}
+}
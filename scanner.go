@@ -45,6 +45,8 @@ func (s *Scanner) Scan() (Token, Pos, string) {
 	case '`':
 		s.r.unread()
 		return s.scanIdent(false)
+	case '$':
+		return s.scanParam()
 	case '+':
 		if ch1, _ := s.r.read(); ch1 == '=' {
 			return INC, pos, ""
@@ -55,6 +57,8 @@ func (s *Scanner) Scan() (Token, Pos, string) {
 		return MUL, pos, ""
 	case '%':
 		return MOD, pos, ""
+	case '^':
+		return POW, pos, ""
 	case '(':
 		return LPAREN, pos, ""
 	case ')':
@@ -76,6 +80,10 @@ func (s *Scanner) Scan() (Token, Pos, string) {
 	case '-':
 		return SUB, pos, ""
 	case '=':
+		if ch1, _ := s.r.read(); ch1 == '~' {
+			return REGEX, pos, ""
+		}
+		s.r.unread()
 		return EQ, pos, ""
 	case '.':
 		if ch1, _ := s.r.read(); ch1 == '.' {
@@ -85,6 +93,10 @@ func (s *Scanner) Scan() (Token, Pos, string) {
 		return DOT, pos, ""
 	case '|':
 		return BAR, pos, ""
+	case '&':
+		return AMP, pos, ""
+	case '!':
+		return BANG, pos, ""
 	case '<':
 		ch1, _ := s.r.read()
 		if ch1 == '>' {
@@ -167,15 +179,37 @@ func (s *Scanner) scanIdent(lookup bool) (tok Token, pos Pos, lit string) {
 	}
 	lit = buf.String()
 
-	// If the literal matches a keyword then return that keyword.
+	// If the literal matches a keyword then return that keyword, the literal
+	// keeps the text as written since keywords may be used as names.
 	if lookup {
 		if tok = Lookup(lit); tok != IDENT {
-			return tok, pos, ""
+			return tok, pos, lit
 		}
 	}
 	return IDENT, pos, lit
 }
 
+// scanParam consumes the name of a parameter, after the `$`.
+func (s *Scanner) scanParam() (tok Token, pos Pos, lit string) {
+	_, pos = s.r.curr()
+	if ch, _ := s.r.read(); ch == '`' {
+		var err error
+		s.r.unread()
+		if lit, err = ScanString(s.r); err == errBadString {
+			return BADSTRING, pos, lit
+		} else if err == errBadEscape {
+			return BADESCAPE, pos, lit
+		}
+		return PARAM, pos, lit
+	}
+	s.r.unread()
+
+	if lit = ScanBareIdent(s.r); lit == "" {
+		return ILLEGAL, pos, "$"
+	}
+	return PARAM, pos, lit
+}
+
 // scanString consumes a contiguous string of non-quote characters.
 // Quote characters can be consumed if they're first escaped with a backslash.
 func (s *Scanner) scanString() (tok Token, pos Pos, lit string) {
@@ -291,23 +325,40 @@ func (s *Scanner) scanNumber() (tok Token, pos Pos, lit string) {
 	_, _ = buf.WriteString(s.scanDigits())
 
 	// If next code points are a full stop and digit then consume them.
+	// A full stop followed by anything else (e.g. the `..` of a range)
+	// is left for the next scan.
 	isDecimal := false
-	if ch0, _ := s.r.read(); ch0 == '.' {
+	if ch0, _ := s.r.read(); ch0 != '.' {
+		s.r.unread()
+	} else if ch1, _ := s.r.read(); !isDigit(ch1) {
+		s.r.unread()
+		s.r.unread()
+	} else {
 		isDecimal = true
-		if ch1, _ := s.r.read(); isDigit(ch1) {
-			_, _ = buf.WriteRune(ch0)
+		_, _ = buf.WriteRune(ch0)
+		_, _ = buf.WriteRune(ch1)
+		_, _ = buf.WriteString(s.scanDigits())
+	}
+
+	// An exponent always makes it a decimal number.
+	if ch0, _ := s.r.read(); ch0 == 'e' || ch0 == 'E' {
+		_, _ = buf.WriteRune(ch0)
+		ch1, _ := s.r.read()
+		if ch1 == '+' || ch1 == '-' {
 			_, _ = buf.WriteRune(ch1)
-			_, _ = buf.WriteString(s.scanDigits())
-		} else {
-			s.r.unread()
+			ch1, _ = s.r.read()
 		}
-	} else {
 		s.r.unread()
+		if !isDigit(ch1) {
+			return ILLEGAL, pos, buf.String()
+		}
+		_, _ = buf.WriteString(s.scanDigits())
+		return NUMBER, pos, buf.String()
 	}
+	s.r.unread()
 
 	// Read as an integer if it doesn't have a fractional part.
 	if !isDecimal {
-		s.r.unread()
 		return INTEGER, pos, buf.String()
 	}
 	return NUMBER, pos, buf.String()
@@ -358,18 +409,26 @@ func (s *Scanner) skipUntilEndComment() error {
 }
 
 // bufScanner represents a wrapper for scanner to add a buffer.
-// It provides a fixed-length circular buffer that can be unread.
+// It keeps the last read tokens so they can be unread. While a mark is held
+// all the tokens read after it are kept, so the scanner can be reset to it.
 type bufScanner struct {
-	s   *Scanner
-	i   int // buffer index
-	n   int // buffer size
-	buf [3]struct {
-		tok Token
-		pos Pos
-		lit string
-	}
+	s     *Scanner
+	i     int // index of the next token in buf
+	base  int // number of tokens dropped from the front of buf
+	marks int // number of marks held
+	buf   []bufToken
 }
 
+// bufToken is a token kept by the bufScanner.
+type bufToken struct {
+	tok Token
+	pos Pos
+	lit string
+}
+
+// bufSize is the number of tokens that can always be unread.
+const bufSize = 3
+
 // newBufScanner returns a new buffered scanner for a reader.
 func newBufScanner(r io.Reader) *bufScanner {
 	return &bufScanner{s: NewScanner(r)}
@@ -378,26 +437,50 @@ func newBufScanner(r io.Reader) *bufScanner {
 // Scan reads the next token from the scanner.
 func (s *bufScanner) Scan() (tok Token, pos Pos, lit string) {
 	// If we have unread tokens then read them off the buffer first.
-	if s.n > 0 {
-		s.n--
+	if s.i < len(s.buf) {
+		s.i++
 		return s.curr()
 	}
 
-	// Move buffer position forward and save the token.
-	s.i = (s.i + 1) % len(s.buf)
-	buf := &s.buf[s.i]
-	buf.tok, buf.pos, buf.lit = s.s.Scan()
+	// Drop the tokens that can't be unread anymore.
+	if s.marks == 0 && len(s.buf) > 4*bufSize {
+		n := copy(s.buf, s.buf[len(s.buf)-bufSize:])
+		s.base += len(s.buf) - n
+		s.buf = s.buf[:n]
+		s.i = n
+	}
+
+	var t bufToken
+	t.tok, t.pos, t.lit = s.s.Scan()
+	s.buf = append(s.buf, t)
+	s.i++
 
 	return s.curr()
 }
 
 // Unscan pushes the previously token back onto the buffer.
-func (s *bufScanner) Unscan() { s.n++ }
+func (s *bufScanner) Unscan() { s.i-- }
+
+// mark returns the current position and keeps all the tokens read after it
+// until reset or release is called.
+func (s *bufScanner) mark() int {
+	s.marks++
+	return s.base + s.i
+}
+
+// reset moves back to a position returned by mark and releases the mark.
+func (s *bufScanner) reset(m int) {
+	s.i = m - s.base
+	s.marks--
+}
+
+// release releases a mark without moving back.
+func (s *bufScanner) release() { s.marks-- }
 
 // curr returns the last read token.
 func (s *bufScanner) curr() (tok Token, pos Pos, lit string) {
-	buf := &s.buf[(s.i-s.n+len(s.buf))%len(s.buf)]
-	return buf.tok, buf.pos, buf.lit
+	t := &s.buf[s.i-1]
+	return t.tok, t.pos, t.lit
 }
 
 // reader represents a buffered rune reader used by the scanner.
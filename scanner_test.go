@@ -15,13 +15,16 @@ func TestScanInput(t *testing.T) {
 		lit string
 	}{
 		{in: `something`, tok: cypher.IDENT, lit: "something"},
-		{in: `desc`, tok: cypher.DESC, lit: ""},
-		{in: `match`, tok: cypher.MATCH, lit: ""},
-		{in: `or`, tok: cypher.OR, lit: ""},
+		{in: `desc`, tok: cypher.DESC, lit: "desc"},
+		{in: `match`, tok: cypher.MATCH, lit: "match"},
+		{in: `or`, tok: cypher.OR, lit: "or"},
 		{in: `1233`, tok: cypher.INTEGER, lit: "1233"},
 		{in: `3.14`, tok: cypher.NUMBER, lit: "3.14"},
-		{in: `true`, tok: cypher.TRUE, lit: ""},
-		{in: `null`, tok: cypher.NULL, lit: ""},
+		{in: `1e10`, tok: cypher.NUMBER, lit: "1e10"},
+		{in: `2.5E-3`, tok: cypher.NUMBER, lit: "2.5E-3"},
+		{in: `1e+`, tok: cypher.ILLEGAL, lit: "1e+"},
+		{in: `true`, tok: cypher.TRUE, lit: "true"},
+		{in: `null`, tok: cypher.NULL, lit: "null"},
 		{in: `"Hello, world!"`, tok: cypher.STRING, lit: "Hello, world!"},
 		{in: `"String\nwith\nnewline"`, tok: cypher.STRING, lit: "String\nwith\nnewline"},
 		{in: `'String\n'`, tok: cypher.STRING, lit: "String\n"},
@@ -31,9 +34,15 @@ func TestScanInput(t *testing.T) {
 		{in: `<=1`, tok: cypher.LTE, lit: ""},
 		{in: `>`, tok: cypher.GT, lit: ""},
 		{in: `>=`, tok: cypher.GTE, lit: ""},
+		{in: `=~`, tok: cypher.REGEX, lit: ""},
+		{in: `=1`, tok: cypher.EQ, lit: ""},
 		{in: `..`, tok: cypher.DOUBLEDOT, lit: ""},
 		{in: `+`, tok: cypher.PLUS, lit: ""},
 		{in: `+=`, tok: cypher.INC, lit: ""},
+		{in: `^`, tok: cypher.POW, lit: ""},
+		{in: `|`, tok: cypher.BAR, lit: ""},
+		{in: `&`, tok: cypher.AMP, lit: ""},
+		{in: `!`, tok: cypher.BANG, lit: ""},
 		{in: `//nice try`, tok: cypher.COMMENT, lit: ""},
 		{in: `/*nice another\n try*/`, tok: cypher.COMMENT, lit: ""},
 		{in: `/`, tok: cypher.DIV, lit: ""},
@@ -41,6 +50,10 @@ func TestScanInput(t *testing.T) {
 		{in: `[`, tok: cypher.LBRACKET, lit: ""},
 		{in: "`nice`", tok: cypher.IDENT, lit: "nice"},
 		{in: "`true`", tok: cypher.IDENT, lit: "true"},
+		{in: `$name`, tok: cypher.PARAM, lit: "name"},
+		{in: `$`, tok: cypher.ILLEGAL, lit: "$"},
+		{in: `$0`, tok: cypher.PARAM, lit: "0"},
+		{in: "$`my param`", tok: cypher.PARAM, lit: "my param"},
 	} {
 		s := cypher.NewScanner(strings.NewReader(tc.in))
 		tok, _, lit := s.Scan()
@@ -59,7 +72,7 @@ func TestScanMultiple(t *testing.T) {
 		lit string
 	}
 	exp := []result{
-		{tok: cypher.MATCH, pos: cypher.Pos{Line: 0, Char: 0}, lit: ""},
+		{tok: cypher.MATCH, pos: cypher.Pos{Line: 0, Char: 0}, lit: "MATCH"},
 		{tok: cypher.WS, pos: cypher.Pos{Line: 0, Char: 5}, lit: " "},
 		{tok: cypher.LPAREN, pos: cypher.Pos{Line: 0, Char: 6}, lit: ""},
 		{tok: cypher.IDENT, pos: cypher.Pos{Line: 0, Char: 7}, lit: "n"},
@@ -67,7 +80,7 @@ func TestScanMultiple(t *testing.T) {
 		{tok: cypher.IDENT, pos: cypher.Pos{Line: 0, Char: 9}, lit: "Person"},
 		{tok: cypher.RPAREN, pos: cypher.Pos{Line: 0, Char: 15}, lit: ""},
 		{tok: cypher.WS, pos: cypher.Pos{Line: 0, Char: 16}, lit: " "},
-		{tok: cypher.WHERE, pos: cypher.Pos{Line: 0, Char: 17}, lit: ""},
+		{tok: cypher.WHERE, pos: cypher.Pos{Line: 0, Char: 17}, lit: "WHERE"},
 		{tok: cypher.WS, pos: cypher.Pos{Line: 0, Char: 22}, lit: " "},
 		{tok: cypher.IDENT, pos: cypher.Pos{Line: 0, Char: 23}, lit: "n"},
 		{tok: cypher.DOT, pos: cypher.Pos{Line: 0, Char: 24}, lit: ""},
@@ -77,7 +90,7 @@ func TestScanMultiple(t *testing.T) {
 		{tok: cypher.WS, pos: cypher.Pos{Line: 0, Char: 31}, lit: " "},
 		{tok: cypher.STRING, pos: cypher.Pos{Line: 0, Char: 31}, lit: "Rafael"},
 		{tok: cypher.WS, pos: cypher.Pos{Line: 0, Char: 40}, lit: " "},
-		{tok: cypher.RETURN, pos: cypher.Pos{Line: 0, Char: 41}, lit: ""},
+		{tok: cypher.RETURN, pos: cypher.Pos{Line: 0, Char: 41}, lit: "RETURN"},
 		{tok: cypher.WS, pos: cypher.Pos{Line: 0, Char: 47}, lit: " "},
 		{tok: cypher.IDENT, pos: cypher.Pos{Line: 0, Char: 48}, lit: "n"},
 		{tok: cypher.EOF, pos: cypher.Pos{Line: 0, Char: 50}, lit: ""},
@@ -109,3 +122,14 @@ func TestScanMultiple(t *testing.T) {
 		}
 	}
 }
+
+func TestScanRange(t *testing.T) {
+	exp := []cypher.Token{cypher.MUL, cypher.INTEGER, cypher.DOUBLEDOT, cypher.INTEGER, cypher.RBRACKET, cypher.EOF}
+
+	s := cypher.NewScanner(strings.NewReader(`*2..5]`))
+	for i, tok := range exp {
+		if act, _, lit := s.Scan(); act != tok {
+			t.Fatalf("%d. token mismatch: exp=%s got=%s (%s)", i, tok, act, lit)
+		}
+	}
+}
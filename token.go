@@ -23,23 +23,27 @@ const (
 	TRUE      // true
 	FALSE     // false
 	NULL      // null
+	PARAM     // $param
 	literalEnd
 
 	operatorBeg
-	PLUS // +
-	SUB  // -
-	MUL  // *
-	DIV  // /
-	MOD  // %
-	POW  // ^
-	EQ   // =
-	NEQ  // <>
-	LT   // <
-	LTE  // <=
-	GT   // >
-	GTE  // >=
-	INC  // +=
-	BAR  // |
+	PLUS  // +
+	SUB   // -
+	MUL   // *
+	DIV   // /
+	MOD   // %
+	POW   // ^
+	EQ    // =
+	NEQ   // <>
+	LT    // <
+	LTE   // <=
+	GT    // >
+	GTE   // >=
+	REGEX // =~
+	INC   // +=
+	BAR   // |
+	AMP   // &
+	BANG  // !
 
 	AND // AND
 	OR  // OR
@@ -67,6 +71,7 @@ const (
 	ASC
 	ASCENDING
 	BY
+	CALL
 	CASE
 	CONSTRAINT
 	CONTAINS
@@ -83,6 +88,7 @@ const (
 	ENDS
 	EXISTS
 	FOR
+	FOREACH
 	IN
 	IS
 	LIMIT
@@ -107,6 +113,7 @@ const (
 	WHEN
 	WHERE
 	WITH
+	YIELD
 	keywordEnd
 )
 
@@ -115,11 +122,14 @@ var tokens = [...]string{
 	EOF:     "EOF",
 	WS:      "WS",
 
-	IDENT:  "IDENT",
-	NUMBER: "NUMBER",
-	STRING: "STRING",
-	TRUE:   "TRUE",
-	FALSE:  "FALSE",
+	IDENT:   "IDENT",
+	NUMBER:  "NUMBER",
+	INTEGER: "INTEGER",
+	STRING:  "STRING",
+	TRUE:    "TRUE",
+	FALSE:   "FALSE",
+	NULL:    "NULL",
+	PARAM:   "PARAM",
 
 	PLUS: "+",
 	SUB:  "-",
@@ -133,12 +143,17 @@ var tokens = [...]string{
 	XOR: "XOR",
 	NOT: "NOT",
 
-	EQ:  "=",
-	NEQ: "<>",
-	LT:  "<",
-	LTE: "<=",
-	GT:  ">",
-	GTE: ">=",
+	EQ:    "=",
+	NEQ:   "<>",
+	LT:    "<",
+	LTE:   "<=",
+	GT:    ">",
+	GTE:   ">=",
+	REGEX: "=~",
+	INC:   "+=",
+	BAR:   "|",
+	AMP:   "&",
+	BANG:  "!",
 
 	LPAREN:    "(",
 	RPAREN:    ")",
@@ -150,6 +165,7 @@ var tokens = [...]string{
 	COLON:     ":",
 	SEMICOLON: ";",
 	DOT:       ".",
+	DOUBLEDOT: "..",
 
 	ADD:        "ADD",
 	ALL:        "ALL",
@@ -157,6 +173,7 @@ var tokens = [...]string{
 	ASC:        "ASC",
 	ASCENDING:  "ASCENDING",
 	BY:         "BY",
+	CALL:       "CALL",
 	CASE:       "CASE",
 	CONSTRAINT: "CONSTRAINT",
 	CONTAINS:   "CONTAINS",
@@ -173,6 +190,7 @@ var tokens = [...]string{
 	ENDS:       "ENDS",
 	EXISTS:     "EXISTS",
 	FOR:        "FOR",
+	FOREACH:    "FOREACH",
 	IN:         "IN",
 	IS:         "IS",
 	LIMIT:      "LIMIT",
@@ -197,6 +215,7 @@ var tokens = [...]string{
 	WHEN:       "WHEN",
 	WHERE:      "WHERE",
 	WITH:       "WITH",
+	YIELD:      "YIELD",
 }
 
 var keywords map[string]Token
@@ -217,6 +236,46 @@ func init() {
 // isOperator returns true for operator tokens.
 func (tok Token) isOperator() bool { return tok > operatorBeg && tok < operatorEnd }
 
+// isKeyword returns true for keyword tokens.
+func (tok Token) isKeyword() bool { return tok > keywordBeg && tok < keywordEnd }
+
+const (
+	// notPrecedence is the precedence of the NOT operator, it binds weaker
+	// than comparisons and stronger than AND.
+	notPrecedence = 4
+	// predicatePrecedence is the precedence of the string, list, regular
+	// expression and null predicates, they bind stronger than comparisons.
+	predicatePrecedence = 6
+	// unaryPrecedence is the precedence of the unary minus and plus.
+	unaryPrecedence = 10
+	// atomPrecedence is the precedence of expressions without operators.
+	atomPrecedence = 12
+)
+
+// Precedence returns the operator precedence of the binary operator or
+// predicate token.
+func (tok Token) Precedence() int {
+	switch tok {
+	case OR:
+		return 1
+	case XOR:
+		return 2
+	case AND:
+		return 3
+	case EQ, NEQ, LT, LTE, GT, GTE:
+		return 5
+	case STARTS, ENDS, CONTAINS, IN, IS, REGEX:
+		return predicatePrecedence
+	case PLUS, SUB:
+		return 7
+	case MUL, DIV, MOD:
+		return 8
+	case POW:
+		return 9
+	}
+	return 0
+}
+
 // String returns the string representation of the token.
 func (tok Token) String() string {
 	if tok >= 0 && tok < Token(len(tokens)) {
@@ -226,11 +285,12 @@ func (tok Token) String() string {
 }
 
 // tokstr returns a literal if provided, otherwise returns the token string.
+// Keywords are reported by their token string rather than as written.
 func tokstr(tok Token, lit string) string {
-	if lit != "" {
-		return lit
+	if lit == "" || tok != IDENT && Lookup(lit) == tok {
+		return tok.String()
 	}
-	return tok.String()
+	return lit
 }
 
 // Lookup returns the token associated with a given string.
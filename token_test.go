@@ -17,6 +17,9 @@ func TestLookupToken(t *testing.T) {
 		{"not", cypher.NOT},
 		{"unique", cypher.UNIQUE},
 		{"starts", cypher.STARTS},
+		{"call", cypher.CALL},
+		{"YIELD", cypher.YIELD},
+		{"foreach", cypher.FOREACH},
 	} {
 		if v := cypher.Lookup(tc.input); v != tc.expected {
 			t.Errorf("Expected token '%s' got '%s'", tc.expected, v)